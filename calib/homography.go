@@ -0,0 +1,216 @@
+// Package calib はスコープの生座標をスクリーンの正規化座標に写す射影変換を求める。
+package calib
+
+import (
+	"errors"
+	"math"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+var (
+	ErrTooFewPoints = errors.New("calib: at least 4 point pairs are required")
+	ErrDegenerate   = errors.New("calib: point configuration is degenerate")
+)
+
+// Homography は射影変換を表す 3x3 行列 (行優先)。
+type Homography [9]float64
+
+// Identity は恒等変換を返す。
+func Identity() Homography {
+	return Homography{
+		1, 0, 0,
+		0, 1, 0,
+		0, 0, 1,
+	}
+}
+
+// Apply は p を射影変換した座標を返す。
+func (h Homography) Apply(p schema.Point) schema.Point {
+	w := h[6]*p.X + h[7]*p.Y + h[8]
+	return schema.Point{
+		X: (h[0]*p.X + h[1]*p.Y + h[2]) / w,
+		Y: (h[3]*p.X + h[4]*p.Y + h[5]) / w,
+	}
+}
+
+// Residuals は src[i] を写した点と dst[i] との距離を返す。
+// 5点以上で求めた変換では、追跡ノイズや歪みの大きい点ほど値が大きくなる。
+func (h Homography) Residuals(src, dst []schema.Point) []float64 {
+	result := make([]float64, len(src))
+	for i := range src {
+		result[i] = h.Apply(src[i]).Dist(dst[i])
+	}
+	return result
+}
+
+// Mul は h・o (o を適用した後に h を適用する変換) を返す。
+func (h Homography) Mul(o Homography) Homography {
+	var r Homography
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i*3+j] += h[i*3+k] * o[k*3+j]
+			}
+		}
+	}
+	return r
+}
+
+// Solve は src[i] を dst[i] に写す射影変換を DLT (Direct Linear Transform) で求める。
+//
+// quality は解の一意性を示す 0-1 の値で、正規化した係数行列の
+// 2番目に小さい特異値と最大特異値の比。同じ場所を2回撃った場合や
+// 3点が一直線に並んだ場合など、解が一意に定まらない配置では 0 に近づく。
+func Solve(src, dst []schema.Point) (h Homography, quality float64, err error) {
+	if len(src) != len(dst) || len(src) < 4 {
+		return Homography{}, 0, ErrTooFewPoints
+	}
+
+	// Hartley の正規化で数値的な条件を改善する
+	srcT, srcN, ok := normalize(src)
+	if !ok {
+		return Homography{}, 0, ErrDegenerate
+	}
+	dstT, dstN, ok := normalize(dst)
+	if !ok {
+		return Homography{}, 0, ErrDegenerate
+	}
+
+	// A^T A を組み立てる (A は 2N x 9)
+	var ata [9][9]float64
+	for i := range srcN {
+		x, y := srcN[i].X, srcN[i].Y
+		u, v := dstN[i].X, dstN[i].Y
+		rows := [2][9]float64{
+			{-x, -y, -1, 0, 0, 0, u * x, u * y, u},
+			{0, 0, 0, -x, -y, -1, v * x, v * y, v},
+		}
+		for _, row := range rows {
+			for r := 0; r < 9; r++ {
+				for c := 0; c < 9; c++ {
+					ata[r][c] += row[r] * row[c]
+				}
+			}
+		}
+	}
+
+	// 最小固有値の固有ベクトルが解になる
+	values, vectors := eigenSymmetric(ata)
+	order := [9]int{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	for i := 1; i < 9; i++ {
+		for j := i; j > 0 && values[order[j]] < values[order[j-1]]; j-- {
+			order[j], order[j-1] = order[j-1], order[j]
+		}
+	}
+	largest := values[order[8]]
+	if largest <= 0 {
+		return Homography{}, 0, ErrDegenerate
+	}
+	quality = math.Sqrt(max(values[order[1]], 0) / largest)
+
+	var hn Homography
+	for i := 0; i < 9; i++ {
+		hn[i] = vectors[i][order[0]]
+	}
+
+	// 正規化を戻す: H = Tdst^-1 * Hn * Tsrc
+	h = inverseSimilarity(dstT).Mul(hn).Mul(srcT)
+	if math.Abs(h[8]) < 1e-12 {
+		return Homography{}, 0, ErrDegenerate
+	}
+	for i := range h {
+		h[i] /= h[8]
+	}
+	return h, quality, nil
+}
+
+// normalize は重心が原点、原点からの平均距離が √2 になるように points を変換する。
+func normalize(points []schema.Point) (Homography, []schema.Point, bool) {
+	var center schema.Point
+	for _, p := range points {
+		center = center.Add(p)
+	}
+	center = center.Scale(1 / float64(len(points)))
+
+	meanDist := 0.0
+	for _, p := range points {
+		meanDist += p.Dist(center)
+	}
+	meanDist /= float64(len(points))
+	if meanDist < 1e-12 {
+		return Homography{}, nil, false
+	}
+
+	s := math.Sqrt2 / meanDist
+	t := Homography{
+		s, 0, -s * center.X,
+		0, s, -s * center.Y,
+		0, 0, 1,
+	}
+	result := make([]schema.Point, len(points))
+	for i, p := range points {
+		result[i] = p.Sub(center).Scale(s)
+	}
+	return t, result, true
+}
+
+// inverseSimilarity は normalize が返した相似変換の逆変換を返す。
+func inverseSimilarity(t Homography) Homography {
+	s := t[0]
+	return Homography{
+		1 / s, 0, -t[2] / s,
+		0, 1 / s, -t[5] / s,
+		0, 0, 1,
+	}
+}
+
+// eigenSymmetric は対称行列 a の固有値と固有ベクトル (列) を Jacobi 法で求める。
+func eigenSymmetric(a [9][9]float64) ([9]float64, [9][9]float64) {
+	var v [9][9]float64
+	for i := 0; i < 9; i++ {
+		v[i][i] = 1
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		off := 0.0
+		for p := 0; p < 9; p++ {
+			for q := p + 1; q < 9; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < 9; p++ {
+			for q := p + 1; q < 9; q++ {
+				if math.Abs(a[p][q]) < 1e-300 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < 9; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 9; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 9; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+	var values [9]float64
+	for i := 0; i < 9; i++ {
+		values[i] = a[i][i]
+	}
+	return values, v
+}
@@ -0,0 +1,73 @@
+package calib
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+func TestSolveKnownHomography(t *testing.T) {
+	want := Homography{
+		1.2, 0.1, 0.05,
+		-0.05, 0.9, 0.1,
+		0.1, 0.2, 1,
+	}
+	src := []schema.Point{
+		{X: 0.1, Y: 0.1},
+		{X: 0.9, Y: 0.1},
+		{X: 0.5, Y: 0.5},
+		{X: 0.9, Y: 0.9},
+		{X: 0.1, Y: 0.9},
+	}
+	dst := make([]schema.Point, len(src))
+	for i, p := range src {
+		dst[i] = want.Apply(p)
+	}
+
+	h, quality, err := Solve(src, dst)
+	if err != nil {
+		t.Fatalf("Solve: %v", err)
+	}
+	if quality < 0.05 {
+		t.Errorf("quality = %v, want a well-conditioned solution", quality)
+	}
+	for i := range h {
+		if math.Abs(h[i]-want[i]) > 1e-6 {
+			t.Errorf("h = %v, want %v", h, want)
+			break
+		}
+	}
+	for i, r := range h.Residuals(src, dst) {
+		if r > 1e-9 {
+			t.Errorf("residual[%d] = %v", i, r)
+		}
+	}
+}
+
+func TestSolveCollinearIsDegenerate(t *testing.T) {
+	src := []schema.Point{
+		{X: 0.1, Y: 0.1},
+		{X: 0.3, Y: 0.3},
+		{X: 0.6, Y: 0.6},
+		{X: 0.9, Y: 0.9},
+	}
+	dst := []schema.Point{
+		{X: 0.25, Y: 0.25},
+		{X: 0.75, Y: 0.25},
+		{X: 0.75, Y: 0.75},
+		{X: 0.25, Y: 0.75},
+	}
+	_, quality, err := Solve(src, dst)
+	if err == nil && quality >= 0.05 {
+		t.Errorf("quality = %v for collinear points, want a low quality or an error", quality)
+	}
+}
+
+func TestSolveTooFewPoints(t *testing.T) {
+	points := []schema.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}
+	if _, _, err := Solve(points, points); !errors.Is(err, ErrTooFewPoints) {
+		t.Errorf("err = %v, want ErrTooFewPoints", err)
+	}
+}
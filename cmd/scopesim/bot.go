@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nobonobo/rtcconnect/node"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/nobonobo/gun-shooter/schema"
+	"github.com/nobonobo/gun-shooter/signaling"
+)
+
+// calibrationDelay はキャリブレーションターゲットが変わってから撃つまでの間。
+const calibrationDelay = 500 * time.Millisecond
+
+type BotInfo struct {
+	Name       string
+	Dest       string
+	Signal     string // 空なら rtcconnect の公開サービスを使う
+	PIN        string
+	Codec      string
+	Rate       time.Duration // 照準を送る間隔
+	Trajectory Trajectory
+	Shots      ShotPattern
+}
+
+// Bot は scope/main.go の Application と同じ手順でホストに接続する合成プレイヤー。
+type Bot struct {
+	info   BotInfo
+	uid    string
+	node   signaling.Transport
+	aimDC  *webrtc.DataChannel
+	shotDC *webrtc.DataChannel
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	seq    uint32
+	shotID uint32
+	status *schema.Status
+	sim    *schema.SimState
+
+	calibIndex int       // 最後に撃ったキャリブレーションターゲット
+	calibSince time.Time // 現在のキャリブレーションターゲットになった時刻
+	mode       string    // 最後に見た Status.Mode
+	readySent  bool      // この待機中に Ready を送ったかどうか。モードが変わったら戻す
+}
+
+func NewBot(info BotInfo) *Bot {
+	uid, _ := uuid.NewV6()
+	var n signaling.Transport = node.New(uid.String())
+	if info.Signal != "" {
+		n = signaling.New(info.Signal, uid.String())
+	}
+	return &Bot{
+		info:       info,
+		uid:        uid.String(),
+		node:       n,
+		logger:     slog.With(slog.String("bot", info.Name)),
+		calibIndex: -1,
+	}
+}
+
+// Run は接続してから ctx が終わるまで照準と射撃を送り続ける。
+func (b *Bot) Run(ctx context.Context) error {
+	if err := b.node.Connect(ctx, b.info.Dest); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer b.node.Close()
+	b.node.DataChannel().OnMessage(b.onMessage)
+	if err := b.openChannels(ctx); err != nil {
+		return err
+	}
+	if err := b.send(b.node.DataChannel(), schema.Hello{
+		ID:     b.uid,
+		Name:   b.info.Name,
+		Codec:  b.info.Codec,
+		PIN:    b.info.PIN,
+		Device: schema.DeviceSimulator,
+	}); err != nil {
+		return fmt.Errorf("failed to send hello: %w", err)
+	}
+	b.logger.Info("Connected", slog.String("id", b.uid))
+	defer b.send(b.node.DataChannel(), schema.Leave{})
+
+	ticker := time.NewTicker(b.info.Rate)
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(time.Second)
+	defer heartbeat.Stop()
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			b.send(b.node.DataChannel(), schema.Heartbeat{})
+		case now := <-ticker.C:
+			if err := b.update(now, now.Sub(start)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *Bot) update(now time.Time, elapsed time.Duration) error {
+	b.mu.Lock()
+	status, sim := b.status, b.sim
+	b.mu.Unlock()
+
+	aim := b.info.Trajectory.Aim(elapsed, sim)
+	fire := false
+	if status != nil && status.Mode != b.mode {
+		b.mode = status.Mode
+		b.readySent = false
+	}
+	if status != nil && status.Mode == schema.ModeWaiting && !status.Ready && !b.readySent {
+		if err := b.send(b.node.DataChannel(), schema.Ready{}); err != nil {
+			return fmt.Errorf("failed to send ready: %w", err)
+		}
+		b.readySent = true
+	}
+	if status != nil && sim != nil && status.Mode == schema.ModeCalibration {
+		// キャリブレーション中はターゲットの位置をそのまま撃つので、恒等変換で校正される
+		aim = sim.CalibrationTarget
+		if status.CalibrationIndex != b.calibIndex {
+			if b.calibSince.IsZero() {
+				b.calibSince = now
+			}
+			if now.Sub(b.calibSince) >= calibrationDelay {
+				fire = true
+				b.calibIndex = status.CalibrationIndex
+				b.calibSince = time.Time{}
+			}
+		}
+	} else {
+		b.calibIndex = -1
+		fire = status != nil && status.Mode == schema.ModePlaying && b.info.Shots.Fire(now)
+	}
+
+	if err := b.sendAim(aim); err != nil {
+		b.logger.Debug("Aim dropped", slog.String("error", err.Error()))
+	}
+	if fire {
+		b.shotID++
+		if err := b.send(b.shotDC, schema.Fire{
+			ShotID: b.shotID,
+			X:      aim.X,
+			Y:      aim.Y,
+			Time:   now.UnixMilli(),
+		}); err != nil {
+			return fmt.Errorf("failed to send shot: %w", err)
+		}
+	}
+	return nil
+}
+
+// openChannels は照準用と射撃用のデータチャネルを開き、開くまで待つ。
+func (b *Bot) openChannels(ctx context.Context) error {
+	pc := b.node.PeerConnection()
+	ordered := false
+	maxRetransmits := uint16(0)
+	aim, err := pc.CreateDataChannel(schema.ChannelAim, &webrtc.DataChannelInit{
+		Ordered:        &ordered,
+		MaxRetransmits: &maxRetransmits,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s channel: %w", schema.ChannelAim, err)
+	}
+	shot, err := pc.CreateDataChannel(schema.ChannelShot, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s channel: %w", schema.ChannelShot, err)
+	}
+	opened := make(chan struct{}, 2)
+	aim.OnOpen(func() { opened <- struct{}{} })
+	shot.OnOpen(func() { opened <- struct{}{} })
+	for range 2 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-opened:
+		}
+	}
+	b.aimDC, b.shotDC = aim, shot
+	return nil
+}
+
+func (b *Bot) sendAim(aim schema.Point) error {
+	if b.info.Codec != schema.CodecBinary {
+		return b.send(b.aimDC, schema.Aim{X: aim.X, Y: aim.Y})
+	}
+	b.mu.Lock()
+	b.seq++
+	frame := schema.Frame{Seq: b.seq, X: aim.X, Y: aim.Y}
+	b.mu.Unlock()
+	data, err := frame.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return b.aimDC.Send(data)
+}
+
+func (b *Bot) send(dc *webrtc.DataChannel, msg schema.Message) error {
+	b.mu.Lock()
+	b.seq++
+	seq := b.seq
+	b.mu.Unlock()
+	data, err := schema.Encode(msg, seq, time.Now())
+	if err != nil {
+		return err
+	}
+	return dc.SendText(string(data))
+}
+
+func (b *Bot) onMessage(msg webrtc.DataChannelMessage) {
+	_, m, err := schema.Decode(msg.Data)
+	if err != nil {
+		b.logger.Warn("Failed to decode message", slog.String("error", err.Error()))
+		return
+	}
+	switch m := m.(type) {
+	case schema.Ping:
+		b.send(b.node.DataChannel(), schema.Pong{Ping: m.Time, Time: time.Now().UnixMilli()})
+	case schema.Status:
+		b.mu.Lock()
+		b.status = &m
+		b.mu.Unlock()
+	case schema.SimState:
+		b.mu.Lock()
+		b.sim = &m
+		b.mu.Unlock()
+	case schema.Denied:
+		b.logger.Warn("Denied", slog.String("reason", m.Reason))
+	case schema.ShotResult:
+		b.logger.Debug("Shot result",
+			slog.Uint64("shot", uint64(m.ShotID)),
+			slog.Bool("hit", m.Hit),
+			slog.Int("points", m.Points),
+		)
+	}
+}
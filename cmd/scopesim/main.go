@@ -0,0 +1,121 @@
+// Command scopesim はスマートフォンなしでホストを試すためのスコープのシミュレーター。
+//
+// 指定したホストに合成プレイヤーを接続し、キャリブレーションの射撃を済ませてから
+// 照準の軌跡と射撃パターンに従って撃ち続ける。
+//
+//	go run ./cmd/scopesim -dest <host id> -n 4 -trajectory targets -shots random
+//
+// ホストは新しいスコープを承認するまで参加させず、ターゲットの位置も送らないので、
+// approve=auto と debug=1 を付けて起動しておく。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+type options struct {
+	dest       string
+	signal     string
+	pin        string
+	count      int
+	name       string
+	trajectory string
+	shots      string
+	interval   time.Duration
+	rate       time.Duration
+	codec      string
+	duration   time.Duration
+	seed       int64
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.dest, "dest", "", "host id to connect (required)")
+	flag.StringVar(&opts.signal, "signal", "", "signaling server URL (empty uses the public rtcconnect service)")
+	flag.StringVar(&opts.pin, "pin", "", "room PIN")
+	flag.IntVar(&opts.count, "n", 1, "number of synthetic players")
+	flag.StringVar(&opts.name, "name", "bot", "player name prefix")
+	flag.StringVar(&opts.trajectory, "trajectory", "sweep", "aim trajectory: sweep, jitter, targets or random")
+	flag.StringVar(&opts.shots, "shots", "interval", "shot pattern: none, interval, burst or random")
+	flag.DurationVar(&opts.interval, "interval", 800*time.Millisecond, "mean interval between shots")
+	flag.DurationVar(&opts.rate, "rate", time.Second/30, "interval between aim updates")
+	flag.StringVar(&opts.codec, "codec", schema.CodecBinary, "aim codec: binary or json")
+	flag.DurationVar(&opts.duration, "duration", 0, "stop after this duration (0 runs until interrupted)")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random seed for repeatable runs")
+	flag.Parse()
+	if opts.dest == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	slog.Info("Started")
+	if err := run(opts); err != nil {
+		slog.Error("Crashed",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	slog.Info("Stopped")
+}
+
+func run(opts options) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	bots := make([]*Bot, opts.count)
+	for i := range bots {
+		// ボットごとに独立した乱数にしてシード固定で再現できるようにする
+		rnd := rand.New(rand.NewSource(opts.seed + int64(i)))
+		tr, err := NewTrajectory(opts.trajectory, rnd)
+		if err != nil {
+			return err
+		}
+		sp, err := NewShotPattern(opts.shots, opts.interval, rnd)
+		if err != nil {
+			return err
+		}
+		bots[i] = NewBot(BotInfo{
+			Name:       fmt.Sprintf("%s%02d", opts.name, i+1),
+			Dest:       opts.dest,
+			Signal:     opts.signal,
+			PIN:        opts.pin,
+			Codec:      opts.codec,
+			Rate:       opts.rate,
+			Trajectory: tr,
+			Shots:      sp,
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(bots))
+	for _, bot := range bots {
+		wg.Go(func() {
+			if err := bot.Run(ctx); err != nil {
+				errs <- fmt.Errorf("%s: %w", bot.info.Name, err)
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	var failed error
+	for err := range errs {
+		slog.Error("Bot failed", slog.String("error", err.Error()))
+		failed = err
+	}
+	return failed
+}
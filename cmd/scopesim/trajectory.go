@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// Trajectory は合成プレイヤーの照準の動き。
+// 座標はキャリブレーション済みの正規化座標 (0-1) で、
+// シミュレーターは生マーカー座標としてそのまま送る。
+type Trajectory interface {
+	Aim(elapsed time.Duration, sim *schema.SimState) schema.Point
+}
+
+func NewTrajectory(name string, rnd *rand.Rand) (Trajectory, error) {
+	switch name {
+	case "sweep":
+		return &sweep{phase: rnd.Float64() * 2 * math.Pi}, nil
+	case "jitter":
+		return &jitter{rnd: rnd}, nil
+	case "targets":
+		return &aimAtTargets{rnd: rnd}, nil
+	case "random":
+		return &random{rnd: rnd}, nil
+	default:
+		return nil, fmt.Errorf("unknown trajectory %q", name)
+	}
+}
+
+// sweep は画面全体をリサージュ曲線でなぞる。
+type sweep struct {
+	phase float64
+}
+
+func (s *sweep) Aim(elapsed time.Duration, _ *schema.SimState) schema.Point {
+	t := elapsed.Seconds()
+	return schema.Point{
+		X: 0.5 + 0.45*math.Sin(2*math.Pi*t/4+s.phase),
+		Y: 0.5 + 0.45*math.Sin(2*math.Pi*t/3+s.phase),
+	}
+}
+
+// jitter は 2 秒ごとに選んだ点の周りで手ぶれのように揺れる。
+type jitter struct {
+	rnd    *rand.Rand
+	center schema.Point
+	until  time.Duration
+}
+
+func (j *jitter) Aim(elapsed time.Duration, _ *schema.SimState) schema.Point {
+	if elapsed >= j.until {
+		j.center = schema.Point{X: 0.2 + j.rnd.Float64()*0.6, Y: 0.2 + j.rnd.Float64()*0.6}
+		j.until = elapsed + 2*time.Second
+	}
+	return schema.Point{
+		X: j.center.X + j.rnd.NormFloat64()*0.01,
+		Y: j.center.Y + j.rnd.NormFloat64()*0.01,
+	}
+}
+
+// aimAtTargets はホストから届いたターゲットのうち最も古いものを少しずれて狙う。
+type aimAtTargets struct {
+	rnd    *rand.Rand
+	offset schema.Point
+}
+
+func (a *aimAtTargets) Aim(_ time.Duration, sim *schema.SimState) schema.Point {
+	if sim == nil || len(sim.Targets) == 0 {
+		return schema.Point{X: 0.5, Y: 0.5}
+	}
+	if a.rnd.Intn(30) == 0 {
+		a.offset = schema.Point{X: a.rnd.NormFloat64() * 0.02, Y: a.rnd.NormFloat64() * 0.02}
+	}
+	tgt := sim.Targets[0]
+	return schema.Point{X: tgt.X + a.offset.X, Y: tgt.Y + a.offset.Y}
+}
+
+// random は 0.5 秒ごとにランダムな位置へ照準を飛ばす。
+type random struct {
+	rnd   *rand.Rand
+	pos   schema.Point
+	until time.Duration
+}
+
+func (r *random) Aim(elapsed time.Duration, _ *schema.SimState) schema.Point {
+	if elapsed >= r.until {
+		r.pos = schema.Point{X: r.rnd.Float64(), Y: r.rnd.Float64()}
+		r.until = elapsed + 500*time.Millisecond
+	}
+	return r.pos
+}
+
+// ShotPattern はプレイ中に引き金を引くタイミング。
+type ShotPattern interface {
+	Fire(now time.Time) bool
+}
+
+func NewShotPattern(name string, interval time.Duration, rnd *rand.Rand) (ShotPattern, error) {
+	switch name {
+	case "none":
+		return noShots{}, nil
+	case "interval":
+		return &intervalShots{interval: interval}, nil
+	case "burst":
+		return &burstShots{interval: interval}, nil
+	case "random":
+		return &randomShots{interval: interval, rnd: rnd}, nil
+	default:
+		return nil, fmt.Errorf("unknown shot pattern %q", name)
+	}
+}
+
+type noShots struct{}
+
+func (noShots) Fire(time.Time) bool { return false }
+
+// intervalShots は一定間隔で撃つ。
+type intervalShots struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func (s *intervalShots) Fire(now time.Time) bool {
+	if now.Before(s.next) {
+		return false
+	}
+	s.next = now.Add(s.interval)
+	return true
+}
+
+// burstShots は 3 連射してから interval だけ休む。
+type burstShots struct {
+	interval time.Duration
+	next     time.Time
+	count    int
+}
+
+func (s *burstShots) Fire(now time.Time) bool {
+	if now.Before(s.next) {
+		return false
+	}
+	s.count++
+	if s.count%3 == 0 {
+		s.next = now.Add(s.interval)
+	} else {
+		s.next = now.Add(80 * time.Millisecond)
+	}
+	return true
+}
+
+// randomShots は平均 interval の指数分布の間隔で撃つ。
+type randomShots struct {
+	interval time.Duration
+	rnd      *rand.Rand
+	next     time.Time
+}
+
+func (s *randomShots) Fire(now time.Time) bool {
+	if now.Before(s.next) {
+		return false
+	}
+	s.next = now.Add(time.Duration(s.rnd.ExpFloat64() * float64(s.interval)))
+	return true
+}
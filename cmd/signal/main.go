@@ -0,0 +1,30 @@
+// Command signal は rtcconnect の公開サービスを使わずにホストとスコープを
+// つなぐためのローカルのシグナリングサーバー。
+//
+//	go run ./cmd/signal -addr :8081
+//
+// ホストとスコープには signal=http://<このマシンのアドレス>:8081 を渡す。
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/nobonobo/gun-shooter/signaling"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	flag.Parse()
+
+	slog.Info("Started", slog.String("addr", *addr))
+	if err := http.ListenAndServe(*addr, signaling.NewServer()); err != nil {
+		slog.Error("Crashed",
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+	slog.Info("Stopped")
+}
@@ -3,8 +3,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 
+	"github.com/google/uuid"
 	nativeapp "github.com/mokiat/lacking-native/app"
 	nativegame "github.com/mokiat/lacking-native/game"
 	nativeui "github.com/mokiat/lacking-native/ui"
@@ -13,9 +15,30 @@ import (
 	"github.com/mokiat/lacking/util/resource"
 
 	"github.com/nobonobo/gun-shooter/host/resources"
+	gameui "github.com/nobonobo/gun-shooter/host/ui"
 )
 
+// ネイティブ版ではブラウザ版の URL パラメータをコマンドラインフラグで渡す。
+func init() {
+	flag.String("id", "", "room id (random if empty)")
+	flag.String("signal", "", "signaling server URL (empty uses the public rtcconnect service)")
+	flag.String("calib", "", "calibration pattern: 4, 5, 9 or x,y;x,y;...")
+	flag.String("rewind", "", "maximum lag compensation rewind, e.g. 200ms")
+	flag.String("pin", "", "room PIN required to join (empty allows anyone with the link)")
+	flag.String("approve", "", "set to auto to skip approving new players")
+	flag.String("debug", "", "send target positions to every scope (for cmd/scopesim)")
+}
+
 func runApplication() error {
+	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		gameui.SetParam(f.Name, f.Value.String())
+	})
+	if gameui.GetParam("id") == "" {
+		uid, _ := uuid.NewV6()
+		gameui.SetParam("id", uid.String())
+	}
+
 	storage, err := chunked.NewFileStorage("./assets")
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
@@ -1,24 +1,75 @@
 package ui
 
 import (
+	"log"
+	"strconv"
+	"time"
+
 	"github.com/mokiat/lacking/game"
 	"github.com/mokiat/lacking/ui"
 	co "github.com/mokiat/lacking/ui/component"
 	"github.com/mokiat/lacking/ui/mvc"
 	"github.com/mokiat/lacking/ui/std"
+
+	"github.com/nobonobo/gun-shooter/rules"
 )
 
 func BootstrapApplication(window *ui.Window, gameController *game.Controller) {
 	engine := gameController.Engine()
 	eventBus := mvc.NewEventBus()
 
+	pattern, err := rules.ParseCalibrationPattern(GetParam("calib"))
+	if err != nil {
+		log.Println("invalid calib param:", err)
+		pattern = rules.Pattern4
+	}
+	var maxRewind time.Duration
+	if s := GetParam("rewind"); s != "" {
+		if maxRewind, err = time.ParseDuration(s); err != nil {
+			log.Println("invalid rewind param:", err)
+			maxRewind = 0
+		}
+	}
+
+	var teams int
+	if s := GetParam("teams"); s != "" {
+		if teams, err = strconv.Atoi(s); err != nil || teams < 0 || teams > rules.MaxTeams {
+			log.Println("invalid teams param:", s)
+			teams = 0
+		}
+	}
+
+	settingsStore := NewSettingsStore()
+	match := rules.DefaultMatchSettings
+	if saved, ok := settingsStore.Load(); ok {
+		match = saved.Normalize()
+	}
+
+	settings := &Settings{
+		CalibrationPattern: pattern,
+		MaxRewind:          maxRewind,
+		PIN:                GetParam("pin"),
+		RequireApproval:    GetParam("approve") != "auto",
+		Teams:              teams,
+		Match:              match,
+		Debug:              GetParam("debug") != "",
+	}
+	// Registry は UI スレッドでしか更新しないので、そのままイベントを流せる
+	session := NewSession(GetParam("id"), settings, func(e PlayerStateChangedEvent) {
+		eventBus.Notify(e)
+	})
+	session.Start()
+
 	scope := co.RootScope(window)
 	scope = co.TypedValueScope(scope, eventBus)
 	scope = co.TypedValueScope(scope, GlobalState{
-		AudioAPI:    window.AudioAPI(),
-		Engine:      engine,
-		ResourceSet: engine.CreateResourceSet(),
-		Actives:     make(map[string]ActiveMember),
+		AudioAPI:      window.AudioAPI(),
+		Engine:        engine,
+		ResourceSet:   engine.CreateResourceSet(),
+		Session:       session,
+		Settings:      settings,
+		Calibrations:  NewCalibrationStore(),
+		SettingsStore: settingsStore,
 	})
 	co.Initialize(scope, co.New(Application, nil))
 }
@@ -29,12 +80,26 @@ type applicationComponent struct {
 	co.BaseComponent
 
 	eventBus   *mvc.EventBus
+	session    *Session
 	activeView ViewName
 }
 
 func (c *applicationComponent) OnCreate() {
 	c.eventBus = co.TypedValue[*mvc.EventBus](c.Scope())
+	c.session = co.TypedValue[GlobalState](c.Scope()).Session
 	c.activeView = ViewNameIntro
+	c.pump()
+}
+
+// pump はどの画面にいても Session の入力を取りこぼさないよう、UI スレッドで定期的に反映する。
+// プレイ画面はこれとは別にフレームごとに反映する。
+func (c *applicationComponent) pump() {
+	c.session.Pump()
+	co.After(c.Scope(), inputPollInterval, c.pump)
+}
+
+func (c *applicationComponent) OnDelete() {
+	c.session.Close()
 }
 
 func (c *applicationComponent) Render() co.Instance {
@@ -73,6 +138,11 @@ func (c *applicationComponent) Render() co.Instance {
 				App: c,
 			})
 		}))
+		co.WithChild(ViewNameSettings, co.New(SettingsScreen, func() {
+			co.WithData(SettingsScreenData{
+				App: c,
+			})
+		}))
 		co.WithChild(ViewNamePlay, co.New(PlayScreen, func() {
 			co.WithData(PlayScreenData{
 				App: c,
@@ -107,6 +177,7 @@ const (
 	ViewNameLicenses ViewName = "licenses"
 	ViewNameHome     ViewName = "home"
 	ViewNameRoom     ViewName = "room"
+	ViewNameSettings ViewName = "settings"
 	ViewNamePlay     ViewName = "play"
 )
 
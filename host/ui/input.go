@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// MaxQueuedInputs は取り出されないまま溜められる PeerEvent の上限。
+// UI スレッドが止まっている間に照準が溜まり続けないようにする。
+const MaxQueuedInputs = 4096
+
+type PeerEventKind int
+
+const (
+	PeerConnected PeerEventKind = iota
+	PeerMessage
+	PeerClosed
+)
+
+// PeerEvent はピアのコールバックで起きたことを UI スレッドに渡すための値。
+type PeerEvent struct {
+	Kind PeerEventKind
+	ID   string
+	Peer Peer
+	// Link はメッセージが届いたチャネルへの返信に使う。
+	Link     *Link
+	Envelope schema.Envelope
+	Message  schema.Message
+	// Received はコールバックで受け取った時刻。到着間隔のゆらぎを測るのに使う。
+	Received time.Time
+}
+
+// InputQueue はピアのゴルーチンから UI スレッドへ PeerEvent を渡す。
+// Push はどのゴルーチンからでも呼べるが、Drain は UI スレッドから呼ぶこと。
+type InputQueue struct {
+	mu      sync.Mutex
+	events  []PeerEvent
+	dropped int
+}
+
+func NewInputQueue() *InputQueue {
+	return &InputQueue{}
+}
+
+func (q *InputQueue) Push(e PeerEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.events) >= MaxQueuedInputs {
+		q.dropped++
+		return
+	}
+	q.events = append(q.events, e)
+}
+
+// Drain は溜まった PeerEvent を届いた順に取り出す。
+func (q *InputQueue) Drain() []PeerEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	events := q.events
+	q.events = nil
+	if q.dropped > 0 {
+		log.Println("input queue overflowed, dropped:", q.dropped)
+		q.dropped = 0
+	}
+	return events
+}
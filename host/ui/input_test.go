@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// TestInputQueueConcurrentPush は go test -race で Push と Drain の競合を確かめる。
+func TestInputQueueConcurrentPush(t *testing.T) {
+	const (
+		producers = 8
+		perPeer   = 500 // 合計が MaxQueuedInputs を超えないので取りこぼさない
+	)
+	q := NewInputQueue()
+	ids := make([]string, producers)
+	for i := range ids {
+		ids[i] = string(rune('a' + i))
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Go(func() {
+			for seq := range perPeer {
+				q.Push(PeerEvent{
+					Kind:     PeerMessage,
+					ID:       id,
+					Envelope: schema.Envelope{Seq: uint32(seq)},
+				})
+			}
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	next := map[string]uint32{}
+	received := 0
+	check := func(events []PeerEvent) {
+		for _, e := range events {
+			// 同じピアのイベントは届いた順に取り出される
+			if e.Envelope.Seq != next[e.ID] {
+				t.Fatalf("peer %s: seq %d, want %d", e.ID, e.Envelope.Seq, next[e.ID])
+			}
+			next[e.ID]++
+			received++
+		}
+	}
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		check(q.Drain())
+	}
+	check(q.Drain())
+	if received != producers*perPeer {
+		t.Errorf("received %d events, want %d", received, producers*perPeer)
+	}
+}
+
+func TestInputQueueDropsOverflow(t *testing.T) {
+	q := NewInputQueue()
+	for range MaxQueuedInputs + 10 {
+		q.Push(PeerEvent{Kind: PeerMessage})
+	}
+	if got := len(q.Drain()); got != MaxQueuedInputs {
+		t.Errorf("drained %d events, want %d", got, MaxQueuedInputs)
+	}
+	if got := len(q.Drain()); got != 0 {
+		t.Errorf("second drain returned %d events", got)
+	}
+}
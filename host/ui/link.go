@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+	"github.com/pion/webrtc/v4"
+)
+
+// Link はスコープへメッセージを送るための制御チャネル。
+// 複数のゴルーチンから Send してよい。
+type Link struct {
+	mu  sync.Mutex
+	dc  *webrtc.DataChannel
+	seq uint32
+}
+
+func NewLink(dc *webrtc.DataChannel) *Link {
+	return &Link{dc: dc}
+}
+
+// Send は msg を Envelope に包んでスコープへ送る。
+func (l *Link) Send(msg schema.Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	b, err := schema.Encode(msg, l.seq, time.Now())
+	if err != nil {
+		return err
+	}
+	return l.dc.SendText(string(b))
+}
@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mokiat/gog/opt"
+	"github.com/mokiat/lacking/ui"
+	co "github.com/mokiat/lacking/ui/component"
+	"github.com/mokiat/lacking/ui/layout"
+	"github.com/mokiat/lacking/ui/std"
+)
+
+// NetStatsPanel はプレイヤーごとの受信状況を一覧する診断パネル。
+// 表示している間は statsSampleInterval ごとに Registry から読み直す。
+var NetStatsPanel = co.Define[*netStatsPanelComponent]()
+
+type netStatsPanelComponent struct {
+	co.BaseComponent
+
+	session *Session
+	font    *ui.Font
+	rows    []string
+}
+
+func (c *netStatsPanelComponent) OnCreate() {
+	c.session = co.TypedValue[GlobalState](c.Scope()).Session
+	c.font = co.OpenFont(c.Scope(), "ui:///roboto-regular.ttf")
+	c.refresh()
+	co.Every(c.Scope(), statsSampleInterval, c.refresh)
+}
+
+func (c *netStatsPanelComponent) refresh() {
+	c.rows = nil
+	for _, active := range c.session.Players.Members() {
+		if active.State == PlayerConnecting || active.State == PlayerLeft {
+			continue
+		}
+		c.rows = append(c.rows, netStatsRow(active))
+	}
+	c.Invalidate()
+}
+
+// netStatsRow は 1 人分の受信状況を 1 行にまとめる。
+func netStatsRow(active ActiveMember) string {
+	s := active.Stats
+	rtt := "-"
+	if active.Clock != nil && active.Clock.RTT() > 0 {
+		rtt = active.Clock.RTT().Round(time.Millisecond).String()
+	}
+	row := fmt.Sprintf("%s [%s]  rtt %s  %d msg/s  jitter %s  drop %d (%.1f%%)",
+		active.Name(), active.State, rtt, s.Rate,
+		s.Jitter.Round(time.Millisecond), s.Dropped, s.DropRate()*100)
+	if t := s.Transport; t.Valid {
+		row += fmt.Sprintf("  ice %s  rx %dKB tx %dKB",
+			t.RTT.Round(time.Millisecond), t.BytesReceived/1024, t.BytesSent/1024)
+	}
+	return row
+}
+
+func (c *netStatsPanelComponent) Render() co.Instance {
+	return co.New(std.Container, func() {
+		co.WithLayoutData(c.Properties().LayoutData())
+		co.WithData(std.ContainerData{
+			BackgroundColor: opt.V(ui.RGBA(0, 0, 0, 180)),
+			Padding:         ui.Spacing{Left: 10, Right: 10, Top: 5, Bottom: 5},
+			Layout: layout.Vertical(layout.VerticalSettings{
+				ContentAlignment: layout.HorizontalAlignmentLeft,
+				ContentSpacing:   2,
+			}),
+		})
+
+		co.WithChild("title", co.New(std.Label, func() {
+			co.WithData(std.LabelData{
+				Font:      c.font,
+				FontSize:  opt.V(float32(18)),
+				FontColor: opt.V(ui.Yellow()),
+				Text:      "Network",
+			})
+		}))
+		for i, row := range c.rows {
+			co.WithChild(fmt.Sprintf("row-%d", i), co.New(std.Label, func() {
+				co.WithData(std.LabelData{
+					Font:      c.font,
+					FontSize:  opt.V(float32(16)),
+					FontColor: opt.V(ui.White()),
+					Text:      row,
+				})
+			}))
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"time"
+)
+
+// statsSampleInterval は PeerConnection の統計を取り直す間隔。
+const statsSampleInterval = time.Second
+
+// TransportStats は PeerConnection から取った ICE の統計。
+// js ビルドでは取れないので Valid は false のまま。
+type TransportStats struct {
+	Valid         bool
+	RTT           time.Duration // ICE の STUN で測った往復時間
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// NetStats はスコープ 1 台分の受信状況。UI スレッドで更新する。
+type NetStats struct {
+	// Messages は Hello 以降に受け取ったメッセージの数。
+	Messages int
+	// Rate は直近 1 秒間に受け取ったメッセージの数。
+	Rate int
+	// Jitter は照準の到着間隔のゆらぎ。RFC 3550 と同じく 1/16 で平滑化する。
+	Jitter time.Duration
+	// Dropped は届かなかったシーケンス番号の数。
+	// スコープがバッファの詰まりで送らなかった照準も含む。
+	Dropped int
+	// Transport は statsSampleInterval ごとに取り直す。
+	Transport TransportStats
+
+	windowStart time.Time
+	windowCount int
+
+	lastAim      time.Time
+	lastInterval time.Duration
+
+	seqValid bool
+	firstSeq uint32
+	maxSeq   uint32
+	received int
+}
+
+// Observe は時刻 at に seq のメッセージが届いたことを記録する。
+// aim なら照準として到着間隔のゆらぎも測る。
+func (s *NetStats) Observe(seq uint32, at time.Time, aim bool) {
+	s.Messages++
+
+	if at.Sub(s.windowStart) >= time.Second {
+		s.Rate = s.windowCount
+		s.windowStart = at
+		s.windowCount = 0
+	}
+	s.windowCount++
+
+	if aim {
+		if !s.lastAim.IsZero() {
+			interval := at.Sub(s.lastAim)
+			if s.lastInterval > 0 {
+				d := interval - s.lastInterval
+				if d < 0 {
+					d = -d
+				}
+				s.Jitter += (d - s.Jitter) / 16
+			}
+			s.lastInterval = interval
+		}
+		s.lastAim = at
+	}
+
+	// スコープはすべてのチャネルで同じ連番を使うので、
+	// 最初に見た番号から最大の番号までで届かなかった数が欠落になる
+	switch {
+	case !s.seqValid:
+		s.seqValid = true
+		s.firstSeq, s.maxSeq = seq, seq
+	case seq < s.firstSeq:
+		return
+	case seq > s.maxSeq:
+		s.maxSeq = seq
+	}
+	s.received++
+	s.Dropped = max(int(s.maxSeq-s.firstSeq)+1-s.received, 0)
+}
+
+// DropRate は欠落したシーケンス番号の割合。
+func (s *NetStats) DropRate() float64 {
+	if !s.seqValid {
+		return 0
+	}
+	return float64(s.Dropped) / float64(s.maxSeq-s.firstSeq+1)
+}
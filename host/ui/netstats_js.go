@@ -0,0 +1,12 @@
+//go:build js
+
+package ui
+
+import (
+	"github.com/pion/webrtc/v4"
+)
+
+// transportStats はブラウザの getStats が非同期なので取らない。
+func transportStats(pc *webrtc.PeerConnection) TransportStats {
+	return TransportStats{}
+}
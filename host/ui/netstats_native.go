@@ -0,0 +1,29 @@
+//go:build !js
+
+package ui
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// transportStats は選ばれている ICE 候補ペアの統計を返す。
+func transportStats(pc *webrtc.PeerConnection) TransportStats {
+	if pc == nil {
+		return TransportStats{}
+	}
+	for _, s := range pc.GetStats() {
+		pair, ok := s.(webrtc.ICECandidatePairStats)
+		if !ok || !pair.Nominated || pair.State != webrtc.StatsICECandidatePairStateSucceeded {
+			continue
+		}
+		return TransportStats{
+			Valid:         true,
+			RTT:           time.Duration(pair.CurrentRoundTripTime * float64(time.Second)),
+			BytesSent:     pair.BytesSent,
+			BytesReceived: pair.BytesReceived,
+		}
+	}
+	return TransportStats{}
+}
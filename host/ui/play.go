@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"maps"
 	"math"
 	"math/rand"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/mokiat/gog/opt"
@@ -19,6 +19,7 @@ import (
 	"github.com/mokiat/lacking/game/graphics"
 	"github.com/mokiat/lacking/game/physics"
 	"github.com/mokiat/lacking/game/physics/acceleration"
+	"github.com/mokiat/lacking/game/timestep"
 	"github.com/mokiat/lacking/ui"
 	co "github.com/mokiat/lacking/ui/component"
 	"github.com/mokiat/lacking/ui/layout"
@@ -27,12 +28,10 @@ import (
 	"github.com/mokiat/lacking/util/shape3d"
 
 	"github.com/nobonobo/gun-shooter/host/resources"
+	"github.com/nobonobo/gun-shooter/rules"
 	"github.com/nobonobo/gun-shooter/schema"
 )
 
-const MarkerSize = 200
-const TargetRadius = 120
-
 func FetchSound(audioAPI audio.API, engine *game.Engine, name string, target *audio.Media) async.Operation {
 	return async.NewFuncOperation(func() error {
 		file, err := resources.UI.Open(name)
@@ -90,15 +89,6 @@ type PlayScreenData struct {
 	App *applicationComponent
 }
 
-type PlayMode int
-
-const (
-	PlayModeCalibration PlayMode = iota
-	PlayModeCountdown
-	PlayModePlaying
-	PlayModeGameOver
-)
-
 type scorePopup struct {
 	x, y  float64
 	text  []rune
@@ -106,17 +96,13 @@ type scorePopup struct {
 	life  float32 // 1.0 down to 0.0 (total 1.5s)
 }
 
-var (
-	textPlus1 = []rune("+1")
-	textPlus5 = []rune("+5")
-)
-
 type playScreenComponent struct {
 	co.BaseComponent
 
 	app *applicationComponent
 
-	debugVisible bool
+	debugVisible    bool
+	netStatsVisible bool // N キーで切り替える受信状況のパネル
 
 	audioAPI    audio.API
 	engine      *game.Engine
@@ -136,30 +122,29 @@ type playScreenComponent struct {
 
 	globalState GlobalState
 
-	// Game State
-	mode       PlayMode
-	modeTime   time.Duration
-	calibIndex int
+	match       *rules.Match
+	calibNotice string       // キャリブレーション棄却時のメッセージ
+	scorePopups []scorePopup // 命中時のスコアポップアップ
+	currentShot uint32       // 処理中の射撃の ShotID。結果の返信に使う
+	lastStatus  time.Time    // 最後に Status を送った時刻
+	lastFeed    time.Time    // 最後に観戦クライアントへ Feed を送った時刻
 
-	// Targets
-	targets       []target
-	nextSpawnTime time.Time
-	gameDuration  float64      // ゲーム経過時間(秒)
-	scorePopups   []scorePopup // 命中時のスコアポップアップ
+	inputSubscription *timestep.UpdateSubscription
 }
 
+const (
+	// statusInterval はスコープへ Status を送る間隔。
+	statusInterval = 250 * time.Millisecond
+	// feedInterval は観戦クライアントへ Feed を送る間隔。カーソルが滑らかに見える程度にする。
+	feedInterval = 100 * time.Millisecond
+)
+
 type particle struct {
 	x, y   float32
 	vx, vy float32
 	life   float32 // 1.0 down to 0.0
 }
 
-type target struct {
-	x, y      float64 // screen pixel position
-	spawnTime time.Time
-	lifetime  time.Duration
-}
-
 var _ ui.ElementKeyboardHandler = (*playScreenComponent)(nil)
 
 func (c *playScreenComponent) OnCreate() {
@@ -182,11 +167,23 @@ func (c *playScreenComponent) OnCreate() {
 	c.lastUpdateTime = time.Now()
 
 	c.createScene()
+	// ピアから届いた入力はシーンの更新ごとに UI スレッドで取り込む
+	c.inputSubscription = c.scene.SubscribeUpdate(func(time.Duration) {
+		c.globalState.Session.Pump()
+	})
 	c.engine.SetActiveScene(c.scene)
 	c.engine.ResetDeltaTime()
 
-	c.mode = PlayModeCalibration
-	c.ResetAll()
+	c.match = rules.NewMatch(rules.MatchInfo{
+		Width:              float64(c.screenWidth),
+		Height:             float64(c.screenHeight),
+		CalibrationPattern: c.globalState.Settings.CalibrationPattern,
+		MaxRewind:          c.globalState.Settings.MaxRewind,
+		Settings:           c.globalState.Settings.Match,
+	})
+	c.match.OnEvent = c.onMatchEvent
+	// 前の Match の準備は引き継がない
+	c.globalState.Session.Players.ResetReady()
 
 	//Fullscreen(true)
 	log.Println("OnCreate")
@@ -198,206 +195,47 @@ func (c *playScreenComponent) OnRender(element *ui.Element, canvas *ui.Canvas) {
 	// 画面サイズを要素の現在のサイズに同期
 	c.screenWidth = element.Bounds().Width
 	c.screenHeight = element.Bounds().Height
-
-	// メンバーがいない場合はキャリブレーションをスキップ
-	if c.mode == PlayModeCalibration {
-		activeCount := 0
-		for _, active := range c.globalState.Actives {
-			if time.Since(active.Time) <= 5*time.Second {
-				activeCount++
-			}
-		}
-		if activeCount == 0 {
-			c.mode = PlayModeCountdown
-			c.modeTime = 3 * time.Second
-			c.ResetScores()
-		}
-	}
+	c.match.SetScreenSize(float64(c.screenWidth), float64(c.screenHeight))
 
 	now := time.Now()
 	dt := float32(now.Sub(c.lastUpdateTime).Seconds())
 	c.lastUpdateTime = now
 
-	// タイマー更新
-	if c.modeTime > 0 {
-		c.modeTime -= time.Duration(dt * float32(time.Second))
-		if c.modeTime <= 0 {
-			c.modeTime = 0
-			// モード遷移
-			switch c.mode {
-			case PlayModeCountdown:
-				c.mode = PlayModePlaying
-				c.modeTime = 60 * time.Second // ゲーム時間は60秒
-				c.gameDuration = 0
-				c.targets = nil
-				c.nextSpawnTime = now
-			case PlayModePlaying:
-				c.mode = PlayModeGameOver
-				c.targets = nil
-			}
+	players := c.globalState.Session.Players
+	for _, active := range players.Members() {
+		id := active.ID
+		if !active.Approved || active.Spectator {
+			continue
 		}
-	}
-
-	// ターゲットのスポーンと消滅 (プレイ中のみ)
-	if c.mode == PlayModePlaying {
-		c.gameDuration += float64(dt)
-		totalDuration := 60.0
-
-		// 経過割合 0.0 → 1.0
-		progress := c.gameDuration / totalDuration
-		if progress > 1.0 {
-			progress = 1.0
+		if !active.State.Present() {
+			c.match.Leave(id)
+			continue
 		}
-
-		// スポーン間隔: 1.0秒 → 0.33秒 (1/sec → 3/sec)
-		spawnInterval := time.Duration((1.0 - progress*2.0/3.0) * float64(time.Second))
-		if spawnInterval < 333*time.Millisecond {
-			spawnInterval = 333 * time.Millisecond
+		_, known := c.match.Player(id)
+		info := active.Info
+		info.Name = active.Name()
+		c.match.Input(id, info)
+		c.match.Mute(id, active.Muted)
+		c.match.SetTeam(id, active.Team)
+		if !known {
+			c.restoreCalibration(id, active.Info.Device)
 		}
-
-		// 寿命: 6秒 → 2秒
-		lifetime := time.Duration((6.0 - progress*4.0) * float64(time.Second))
-		if lifetime < 2*time.Second {
-			lifetime = 2 * time.Second
-		}
-
-		// スポーン
-		if now.After(c.nextSpawnTime) {
-			margin := float64(TargetRadius + MarkerSize/2)
-			tx := margin + rand.Float64()*float64(float64(c.screenWidth)-2*margin)
-			ty := margin + rand.Float64()*float64(float64(c.screenHeight)-2*margin)
-			c.targets = append(c.targets, target{
-				x:         tx,
-				y:         ty,
-				spawnTime: now,
-				lifetime:  lifetime,
-			})
-			c.nextSpawnTime = now.Add(spawnInterval)
-		}
-
-		// 期限切れのターゲットを除去
-		for i := 0; i < len(c.targets); {
-			if now.Sub(c.targets[i].spawnTime) > c.targets[i].lifetime {
-				c.targets[i] = c.targets[len(c.targets)-1]
-				c.targets = c.targets[:len(c.targets)-1]
-			} else {
-				i++
-			}
+		for _, shot := range players.TakeShots(id) {
+			c.currentShot = shot.ID
+			c.match.Fire(id, shot.Aim, shot.Time)
 		}
+		c.currentShot = 0
 	}
-
-	for id, active := range c.globalState.Actives {
-		if time.Since(active.Time) > 5*time.Second {
-			continue
-		}
-		// Fire == true の場合にパーティクルを生成
-		if active.Info.Fire {
-			active.Info.Fire = false
-
-			// Calibration mode logic
-			if c.mode == PlayModeCalibration {
-				m := c.globalState.Actives[id]
-				// 既にこの箇所のキャリブレーションを終えている、または範囲外なら無視
-				if m.Calibrated == c.calibIndex && m.Calibrated < 4 {
-					m.Calibration[m.Calibrated] = schema.Point{
-						X: active.Info.X,
-						Y: active.Info.Y,
-					}
-					m.Calibrated++
-					c.globalState.Actives[id] = m
-				}
-
-				// 全員が現在のインデックス(c.calibIndex)を完了したかチェック
-				allAdvanced := true
-				hasActiveMembers := false
-				for _, am := range c.globalState.Actives {
-					if time.Since(am.Time) <= 5*time.Second {
-						hasActiveMembers = true
-						if am.Calibrated <= c.calibIndex {
-							allAdvanced = false
-							break
-						}
-					}
-				}
-
-				if hasActiveMembers && allAdvanced {
-					c.calibIndex++
-					if c.calibIndex > 3 {
-						c.mode = PlayModeCountdown
-						c.modeTime = 3 * time.Second
-						c.ResetScores()
-					}
-				}
-				c.audioAPI.Play(c.gunSound, audio.PlayInfo{
-					Gain: opt.V(1.0),
-				})
-				c.Invalidate()
-				continue
-			}
-			pos := active.Calibrate()
-			x := pos.X*float64(c.screenWidth-MarkerSize) + MarkerSize/2
-			y := pos.Y*float64(c.screenHeight-MarkerSize) + MarkerSize/2
-			if x < 0 || y < 0 || x > float64(c.screenWidth) || y > float64(c.screenHeight) {
-				continue
-			}
-
-			// プレイ中: ターゲットに命中した場合のみスコア加算
-			hit := false
-			if c.mode == PlayModePlaying {
-				for ti := 0; ti < len(c.targets); ti++ {
-					dx := x - c.targets[ti].x
-					dy := y - c.targets[ti].y
-					distSq := dx*dx + dy*dy
-					if distSq <= TargetRadius*TargetRadius {
-						dist := math.Sqrt(distSq)
-						points := 1
-						color := ui.Red()
-						txt := textPlus1
-						if dist <= 60 {
-							points = 5
-							color = ui.Yellow()
-							txt = textPlus5
-						}
-
-						m := c.globalState.Actives[id]
-						m.Score += points
-						c.globalState.Actives[id] = m
-						// ターゲットを消す
-						c.targets[ti] = c.targets[len(c.targets)-1]
-						c.targets = c.targets[:len(c.targets)-1]
-						// スコアポップアップを追加
-						c.scorePopups = append(c.scorePopups, scorePopup{
-							x:     x,
-							y:     y,
-							text:  txt,
-							color: color,
-							life:  1.0,
-						})
-						hit = true
-						break
-					}
-				}
-			}
-
-			if hit {
-				c.audioAPI.Play(c.popSound, audio.PlayInfo{
-					Gain: opt.V(1.0),
-				})
-			} else {
-				c.audioAPI.Play(c.gunSound, audio.PlayInfo{
-					Gain: opt.V(1.0),
-				})
-			}
-			for i := 0; i < 5; i++ {
-				c.particles = append(c.particles, particle{
-					x:    float32(x),
-					y:    float32(y),
-					vx:   (rand.Float32() - 0.5) * 500,
-					vy:   (rand.Float32() - 0.5) * 500,
-					life: 1.0,
-				})
-			}
-		}
+	// 参加中の全員がスコープで準備できたらキャリブレーションを始める
+	if c.match.Mode() == rules.ModeWaiting && players.AllReady() {
+		c.match.Start()
+	}
+	c.match.Update()
+	if now.Sub(c.lastStatus) >= statusInterval {
+		c.sendStatus()
+	}
+	if now.Sub(c.lastFeed) >= feedInterval {
+		c.sendFeed()
 	}
 
 	// パーティクルの更新
@@ -414,6 +252,36 @@ func (c *playScreenComponent) OnRender(element *ui.Element, canvas *ui.Canvas) {
 		}
 	}
 
+	// キャリブレーション結果の描画 (カウントダウン中のみ)
+	if c.match.Mode() == rules.ModeCountdown {
+		pattern := c.match.CalibrationPattern()
+		for _, player := range c.match.Players() {
+			if !player.Active || !player.IsCalibrated() {
+				continue
+			}
+			for i, raw := range player.Calibration {
+				tp := c.match.ScreenPosition(pattern[i])
+				sp := c.match.ScreenPosition(player.Calibrate(raw))
+				canvas.Reset()
+				canvas.Circle(sprec.Vec2{X: float32(tp.X), Y: float32(tp.Y)}, 8)
+				canvas.Fill(ui.Fill{
+					Color: ui.White(),
+				})
+				canvas.Reset()
+				canvas.SetStrokeColor(ui.Green())
+				canvas.SetStrokeSize(2)
+				canvas.MoveTo(sprec.Vec2{X: float32(tp.X), Y: float32(tp.Y)})
+				canvas.LineTo(sprec.Vec2{X: float32(sp.X), Y: float32(sp.Y)})
+				canvas.Stroke()
+				canvas.Reset()
+				canvas.Circle(sprec.Vec2{X: float32(sp.X), Y: float32(sp.Y)}, 4)
+				canvas.Fill(ui.Fill{
+					Color: ui.Green(),
+				})
+			}
+		}
+	}
+
 	// パーティクルの描画
 	for _, p := range c.particles {
 		color := ui.RGBA(255, 128, 0, uint8(p.life*255)) // オレンジ色からフェードアウト
@@ -425,26 +293,9 @@ func (c *playScreenComponent) OnRender(element *ui.Element, canvas *ui.Canvas) {
 	}
 
 	// ターゲットの描画
-	if c.mode == PlayModePlaying {
-		for _, tgt := range c.targets {
-			// 外枠 (白)
-			canvas.Reset()
-			canvas.Circle(sprec.Vec2{X: float32(tgt.x), Y: float32(tgt.y)}, float32(TargetRadius))
-			canvas.Fill(ui.Fill{
-				Color: ui.White(),
-			})
-			// 内側 (赤)
-			canvas.Reset()
-			canvas.Circle(sprec.Vec2{X: float32(tgt.x), Y: float32(tgt.y)}, float32(TargetRadius-4))
-			canvas.Fill(ui.Fill{
-				Color: ui.RGBA(255, 40, 40, 200),
-			})
-			// 中心 (黄色)
-			canvas.Reset()
-			canvas.Circle(sprec.Vec2{X: float32(tgt.x), Y: float32(tgt.y)}, 60)
-			canvas.Fill(ui.Fill{
-				Color: ui.Yellow(),
-			})
+	if c.match.Mode() == rules.ModePlaying {
+		for _, tgt := range c.match.Targets() {
+			c.drawTarget(canvas, tgt, now)
 		}
 	}
 
@@ -459,10 +310,10 @@ func (c *playScreenComponent) OnRender(element *ui.Element, canvas *ui.Canvas) {
 	}
 
 	invalid := true
-	if c.mode == PlayModeCalibration {
+	if c.match.Mode() == rules.ModeCalibration {
 		invalid = false // キャリブレーション中は描画更新不要（パーティクルがなければ）
 	}
-	if len(c.particles) > 0 || len(c.scorePopups) > 0 || c.modeTime > 0 || c.mode == PlayModePlaying {
+	if len(c.particles) > 0 || len(c.scorePopups) > 0 || c.match.Remaining() > 0 || c.match.Mode() == rules.ModePlaying {
 		invalid = true
 	}
 
@@ -482,8 +333,420 @@ func (c *playScreenComponent) OnRender(element *ui.Element, canvas *ui.Canvas) {
 	}
 }
 
+// targetColors はターゲットの種類ごとの外枠、内側、中心の色。
+var targetColors = map[rules.TargetKind][3]ui.Color{
+	rules.TargetNormal:    {ui.White(), ui.RGBA(255, 40, 40, 200), ui.Yellow()},
+	rules.TargetBonus:     {ui.Yellow(), ui.RGBA(255, 160, 0, 220), ui.White()},
+	rules.TargetPenalty:   {ui.White(), ui.RGBA(40, 120, 255, 200), ui.RGB(0xAA, 0xDD, 0xFF)},
+	rules.TargetArmored:   {ui.RGB(0x44, 0x44, 0x44), ui.RGBA(140, 140, 150, 230), ui.RGB(0xDD, 0xDD, 0xDD)},
+	rules.TargetTime:      {ui.White(), ui.RGBA(40, 200, 90, 200), ui.Yellow()},
+	rules.TargetShrinking: {ui.White(), ui.RGBA(170, 60, 220, 200), ui.Yellow()},
+}
+
+// drawTarget はターゲットを種類に応じて描く。
+// 人質には撃ってはいけない印を、装甲には残りの命中数を、時間延長には延びる秒数を重ねる。
+func (c *playScreenComponent) drawTarget(canvas *ui.Canvas, tgt rules.Target, now time.Time) {
+	center := sprec.Vec2{X: float32(tgt.X), Y: float32(tgt.Y)}
+	radius := float32(tgt.Radius)
+	colors := targetColors[tgt.Kind]
+	border := float32(4)
+	if tgt.Kind == rules.TargetArmored {
+		border = 12
+	}
+	// 外枠
+	canvas.Reset()
+	canvas.Circle(center, radius)
+	canvas.Fill(ui.Fill{
+		Color: colors[0],
+	})
+	// 内側
+	canvas.Reset()
+	canvas.Circle(center, radius-border)
+	canvas.Fill(ui.Fill{
+		Color: colors[1],
+	})
+	// 中心
+	canvas.Reset()
+	canvas.Circle(center, float32(c.match.BullseyeRadius(tgt, now)))
+	canvas.Fill(ui.Fill{
+		Color: colors[2],
+	})
+
+	var label string
+	switch tgt.Kind {
+	case rules.TargetPenalty:
+		// 斜めの線で × を描く
+		d := radius * 0.6
+		canvas.Reset()
+		canvas.SetStrokeColor(ui.White())
+		canvas.SetStrokeSize(10)
+		canvas.MoveTo(sprec.Vec2{X: center.X - d, Y: center.Y - d})
+		canvas.LineTo(sprec.Vec2{X: center.X + d, Y: center.Y + d})
+		canvas.MoveTo(sprec.Vec2{X: center.X + d, Y: center.Y - d})
+		canvas.LineTo(sprec.Vec2{X: center.X - d, Y: center.Y + d})
+		canvas.Stroke()
+	case rules.TargetArmored:
+		label = fmt.Sprintf("%d", tgt.Hits)
+	case rules.TargetTime:
+		label = fmt.Sprintf("+%ds", int(rules.TimeExtension.Seconds()))
+	}
+	if label != "" {
+		canvas.Reset()
+		canvas.FillTextLine([]rune(label), sprec.Vec2{X: center.X - 20, Y: center.Y - 24}, ui.Typography{
+			Font:  c.textFont,
+			Size:  40,
+			Color: ui.Black(),
+		})
+	}
+}
+
+func (c *playScreenComponent) onMatchEvent(event rules.Event) {
+	switch e := event.(type) {
+	case rules.ModeChanged:
+		c.calibNotice = ""
+		// 次のフレームですぐに新しいモードを知らせる
+		c.lastStatus = time.Time{}
+		c.Invalidate()
+
+	case rules.CalibrationCompleted:
+		c.saveCalibration(e.PlayerID)
+
+	case rules.CalibrationRejected:
+		name := e.PlayerID
+		if player, ok := c.match.Player(e.PlayerID); ok {
+			name = player.Name
+		}
+		log.Printf("calibration rejected: %s quality=%.3f: %v", name, e.Quality, e.Err)
+		c.calibNotice = fmt.Sprintf("%s: calibration rejected, shoot again from the first target", name)
+		c.Invalidate()
+
+	case rules.CalibrationShot:
+		c.sendResult(e.PlayerID, schema.ShotResult{Hit: e.Accepted})
+		c.audioAPI.Play(c.gunSound, audio.PlayInfo{
+			Gain: opt.V(1.0),
+		})
+		c.Invalidate()
+
+	case rules.Hit:
+		result := schema.ShotResult{
+			Hit:       true,
+			Points:    e.Points,
+			Bullseye:  e.Bullseye,
+			Destroyed: e.Destroyed,
+		}
+		if e.Combo > 1 {
+			result.Combo = e.Combo
+		}
+		c.sendResult(e.PlayerID, result)
+		color := ui.Red()
+		if e.Bullseye {
+			color = ui.Yellow()
+		}
+		text := fmt.Sprintf("%+d", e.Points)
+		switch {
+		case !e.Destroyed:
+			// 装甲はまだ壊れていないので残りの回数を出す
+			color = ui.RGB(0xDD, 0xDD, 0xDD)
+			text = fmt.Sprintf("%d", e.Target.Hits)
+		case e.Points < 0:
+			color = ui.RGB(0x34, 0x98, 0xDB)
+		case e.Extension > 0:
+			text += fmt.Sprintf(" +%ds", int(e.Extension.Seconds()))
+		}
+		if e.Combo > 1 {
+			text += fmt.Sprintf(" x%d", e.Combo)
+		}
+		// チーム戦では誰の得点か分かるようにチームの色で出す
+		if player, ok := c.match.Player(e.PlayerID); ok {
+			if teamColor, ok := TeamColor(player.Team); ok {
+				color = teamColor
+			}
+		}
+		// スコアポップアップを追加
+		c.scorePopups = append(c.scorePopups, scorePopup{
+			x:     e.Position.X,
+			y:     e.Position.Y,
+			text:  []rune(text),
+			color: color,
+			life:  1.0,
+		})
+		c.audioAPI.Play(c.popSound, audio.PlayInfo{
+			Gain: opt.V(1.0),
+		})
+		c.spawnParticles(e.Position.X, e.Position.Y)
+
+	case rules.Miss:
+		c.sendResult(e.PlayerID, schema.ShotResult{})
+		c.audioAPI.Play(c.gunSound, audio.PlayInfo{
+			Gain: opt.V(1.0),
+		})
+		c.spawnParticles(e.Position.X, e.Position.Y)
+	}
+}
+
+// playerLabel は HUD と結果一覧に表示する名前とスコア。
+func playerLabel(p *rules.Player) string {
+	if p.Muted {
+		return fmt.Sprintf("%s (muted): %d", p.Name, p.Score)
+	}
+	return fmt.Sprintf("%s: %d", p.Name, p.Score)
+}
+
+// statsLabel は結果一覧に表示するプレイヤーの成績。
+func statsLabel(p *rules.Player) string {
+	s := p.Stats
+	text := fmt.Sprintf("%s: %d/%d hits (%.0f%%), %d bullseyes, best streak %d",
+		p.Name, s.Hits, s.Shots, s.Accuracy()*100, s.Bullseyes, s.BestStreak)
+	if s.Destroyed > 0 {
+		text += fmt.Sprintf(", avg %.2f s", s.AverageReaction().Seconds())
+	}
+	return text
+}
+
+// scoreLabels は HUD と結果一覧のスコアの行を追加する。
+// チーム戦ではチームの合計を高い順に並べ、その下にメンバーの内訳を出す。
+// activeOnly なら抜けたプレイヤーの行は出さない (チームの合計には含める)。
+func (c *playScreenComponent) scoreLabels(size float32, activeOnly bool) {
+	label := func(key, text string, color ui.Color, fontSize float32) {
+		co.WithChild(key, co.New(std.Label, func() {
+			co.WithData(std.LabelData{
+				Font:      c.textFont,
+				FontSize:  opt.V(fontSize),
+				FontColor: opt.V(color),
+				Text:      text,
+			})
+		}))
+	}
+	playerColor := func(player *rules.Player) ui.Color {
+		if player.Muted || !player.Active {
+			return ui.RGB(0x88, 0x88, 0x88)
+		}
+		return ui.White()
+	}
+
+	teams := c.match.TeamScores()
+	if len(teams) == 0 {
+		for _, player := range c.match.Players() {
+			if activeOnly && !player.Active {
+				continue
+			}
+			label("score-"+player.ID, playerLabel(player), playerColor(player), size)
+		}
+		return
+	}
+	for _, team := range teams {
+		color, _ := TeamColor(team.Team)
+		label(fmt.Sprintf("team-%d", team.Team), teamLabel(team), color, size*1.2)
+		for _, player := range team.Players {
+			if activeOnly && !player.Active {
+				continue
+			}
+			label("score-"+player.ID, "  "+playerLabel(player), playerColor(player), size)
+		}
+	}
+}
+
+// sendResult は処理中の射撃の判定結果を撃ったスコープに返す。
+func (c *playScreenComponent) sendResult(id string, result schema.ShotResult) {
+	active, ok := c.globalState.Session.Players.Get(id)
+	if !ok || active.Link == nil {
+		return
+	}
+	result.ShotID = c.currentShot
+	if err := active.Link.Send(result); err != nil {
+		log.Println("failed to send shot result:", id, err)
+	}
+}
+
+// sendStatus は進行状況とそれぞれの成績を全スコープに送る。
+func (c *playScreenComponent) sendStatus() {
+	c.lastStatus = time.Now()
+	status := schema.Status{
+		Mode:             c.match.Mode().String(),
+		CalibrationIndex: c.match.CalibrationIndex(),
+		CalibrationCount: len(c.match.CalibrationPattern()),
+		Remaining:        c.match.Remaining().Milliseconds(),
+		Players:          len(c.match.Players()),
+	}
+	sim := c.simState()
+	for _, active := range c.globalState.Session.Players.Members() {
+		id := active.ID
+		if active.Link == nil {
+			continue
+		}
+		player, ok := c.match.Player(id)
+		if !ok || !player.Active {
+			continue
+		}
+		status.Ready = active.Ready
+		status.Score = player.Score
+		status.Rank = c.match.Rank(id)
+		// 送れなかった Status は次の周期で送り直されるので捨てる
+		active.Link.Send(status)
+		if c.globalState.Settings.Debug {
+			active.Link.Send(sim)
+		}
+	}
+}
+
+// simState はシミュレーターが狙うためのキャリブレーションターゲットとターゲットの位置。
+func (c *playScreenComponent) simState() schema.SimState {
+	var sim schema.SimState
+	if pattern, index := c.match.CalibrationPattern(), c.match.CalibrationIndex(); index < len(pattern) {
+		sim.CalibrationTarget = pattern[index]
+	}
+	for _, tgt := range c.match.Targets() {
+		sim.Targets = append(sim.Targets, c.match.NormalizedPosition(schema.Point{X: tgt.X, Y: tgt.Y}))
+	}
+	return sim
+}
+
+// sendFeed は試合の様子を観戦クライアントに送る。
+func (c *playScreenComponent) sendFeed() {
+	c.lastFeed = time.Now()
+	var spectators []*Link
+	for _, active := range c.globalState.Session.Players.Members() {
+		if active.Spectator && active.State.Present() && active.Link != nil {
+			spectators = append(spectators, active.Link)
+		}
+	}
+	if len(spectators) == 0 {
+		return
+	}
+	feed := schema.Feed{
+		Mode:      c.match.Mode().String(),
+		Remaining: c.match.Remaining().Milliseconds(),
+		Width:     float64(c.screenWidth),
+		Height:    float64(c.screenHeight),
+		Inset:     rules.MarkerSize / 2,
+	}
+	now := time.Now()
+	for _, tgt := range c.match.Targets() {
+		pos := c.match.NormalizedPosition(schema.Point{X: tgt.X, Y: tgt.Y})
+		feed.Targets = append(feed.Targets, schema.FeedTarget{
+			X:        pos.X,
+			Y:        pos.Y,
+			Radius:   tgt.Radius,
+			Bullseye: c.match.BullseyeRadius(tgt, now),
+			Kind:     tgt.Kind.String(),
+		})
+	}
+	for _, player := range c.match.Players() {
+		feed.Players = append(feed.Players, schema.FeedPlayer{
+			ID:     player.ID,
+			Name:   player.Name,
+			Score:  player.Score,
+			Rank:   c.match.Rank(player.ID),
+			Cursor: player.Position(),
+			Active: player.Active,
+			Team:   player.Team,
+		})
+	}
+	slices.SortStableFunc(feed.Players, func(a, b schema.FeedPlayer) int {
+		return a.Rank - b.Rank
+	})
+	for _, link := range spectators {
+		// 送れなかった Feed は次の周期で新しいものを送るので捨てる
+		link.Send(feed)
+	}
+}
+
+// restoreCalibration は同じスコープ端末・同じ解像度で保存されたキャリブレーションを復元する。
+func (c *playScreenComponent) restoreCalibration(id, device string) {
+	if device == "" {
+		return
+	}
+	record, ok := c.globalState.Calibrations.Load(calibrationKey(device, c.screenWidth, c.screenHeight))
+	if !ok || !slices.Equal(record.Pattern, []schema.Point(c.match.CalibrationPattern())) {
+		return
+	}
+	if err := c.match.RestoreCalibration(id, record.Samples); err != nil {
+		log.Println("failed to restore calibration:", id, err)
+		return
+	}
+	log.Println("calibration restored:", id, device)
+}
+
+func (c *playScreenComponent) saveCalibration(id string) {
+	player, ok := c.match.Player(id)
+	if !ok || player.Device == "" {
+		return
+	}
+	record := CalibrationRecord{
+		Pattern: c.match.CalibrationPattern(),
+		Samples: player.Calibration,
+		Quality: player.CalibrationQuality,
+		SavedAt: time.Now(),
+	}
+	if err := c.globalState.Calibrations.Save(calibrationKey(player.Device, c.screenWidth, c.screenHeight), record); err != nil {
+		log.Println("failed to save calibration:", id, err)
+	}
+}
+
+func (c *playScreenComponent) onRecalibrate() {
+	c.match.Recalibrate()
+	c.Invalidate()
+}
+
+// residualText はキャリブレーション各点の残差をピクセル単位で表示用に整形する。
+func (c *playScreenComponent) residualText(player *rules.Player) string {
+	pattern := c.match.CalibrationPattern()
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s:", player.Name)
+	sum := 0.0
+	for i, raw := range player.Calibration {
+		tp := c.match.ScreenPosition(pattern[i])
+		sp := c.match.ScreenPosition(player.Calibrate(raw))
+		dist := tp.Dist(sp)
+		sum += dist * dist
+		fmt.Fprintf(&builder, " %.0f", dist)
+	}
+	fmt.Fprintf(&builder, " px (rms %.1f)", math.Sqrt(sum/float64(len(player.Calibration))))
+	return builder.String()
+}
+
+// calibrationLabel はキャリブレーションターゲットの位置を言葉で表す。
+func calibrationLabel(p schema.Point) string {
+	var vertical, horizontal string
+	switch {
+	case p.Y < 0.4:
+		vertical = "TOP"
+	case p.Y > 0.6:
+		vertical = "BOTTOM"
+	}
+	switch {
+	case p.X < 0.4:
+		horizontal = "LEFT"
+	case p.X > 0.6:
+		horizontal = "RIGHT"
+	}
+	switch {
+	case vertical != "" && horizontal != "":
+		return vertical + "-" + horizontal
+	case vertical != "":
+		return vertical
+	case horizontal != "":
+		return horizontal
+	default:
+		return "CENTER"
+	}
+}
+
+func (c *playScreenComponent) spawnParticles(x, y float64) {
+	for i := 0; i < 5; i++ {
+		c.particles = append(c.particles, particle{
+			x:    float32(x),
+			y:    float32(y),
+			vx:   (rand.Float32() - 0.5) * 500,
+			vy:   (rand.Float32() - 0.5) * 500,
+			life: 1.0,
+		})
+	}
+}
+
 func (c *playScreenComponent) OnDelete() {
 	log.Println("OnDelete")
+	c.inputSubscription.Delete()
 	c.engine.SetActiveScene(nil)
 	Fullscreen(false)
 }
@@ -495,6 +758,12 @@ func (c *playScreenComponent) OnKeyboardEvent(element *ui.Element, event ui.Keyb
 		c.app.SetActiveView(ViewNameRoom)
 		return true
 
+	case ui.KeyCodeR:
+		if event.Action == ui.KeyboardActionDown {
+			c.onRecalibrate()
+		}
+		return true
+
 	case ui.KeyCodeTab:
 		if event.Action == ui.KeyboardActionDown {
 			c.debugVisible = !c.debugVisible
@@ -502,6 +771,13 @@ func (c *playScreenComponent) OnKeyboardEvent(element *ui.Element, event ui.Keyb
 		}
 		return true
 
+	case ui.KeyCodeN:
+		if event.Action == ui.KeyboardActionDown {
+			c.netStatsVisible = !c.netStatsVisible
+			c.Invalidate()
+		}
+		return true
+
 	default:
 		return false
 	}
@@ -528,6 +804,14 @@ func (c *playScreenComponent) Render() co.Instance {
 				})
 			}))
 		}
+		if c.netStatsVisible {
+			co.WithChild("netstats", co.New(NetStatsPanel, func() {
+				co.WithLayoutData(layout.Data{
+					Top:   opt.V(rules.MarkerSize),
+					Right: opt.V(0),
+				})
+			}))
+		}
 
 		// Marker Images in Corners
 		for i := 0; i < 4; i++ {
@@ -555,8 +839,8 @@ func (c *playScreenComponent) Render() co.Instance {
 					Right:  opt.V(0),
 				}
 			}
-			layoutData.Width = opt.V(MarkerSize)
-			layoutData.Height = opt.V(MarkerSize)
+			layoutData.Width = opt.V(rules.MarkerSize)
+			layoutData.Height = opt.V(rules.MarkerSize)
 
 			co.WithChild(fmt.Sprintf("marker-%d", i), co.New(std.Picture, func() {
 				co.WithLayoutData(layoutData)
@@ -580,27 +864,56 @@ func (c *playScreenComponent) Render() co.Instance {
 				Layout: layout.Anchor(),
 			})
 
-			switch c.mode {
-			case PlayModeCalibration:
+			switch c.match.Mode() {
+			case rules.ModeWaiting:
+				co.WithChild("waiting", co.New(std.Element, func() {
+					co.WithLayoutData(layout.Data{
+						HorizontalCenter: opt.V(0),
+						VerticalCenter:   opt.V(0),
+					})
+					co.WithData(std.ElementData{
+						Layout: layout.Vertical(layout.VerticalSettings{
+							ContentAlignment: layout.HorizontalAlignmentCenter,
+							ContentSpacing:   10,
+						}),
+					})
+
+					co.WithChild("waiting-title", co.New(std.Label, func() {
+						co.WithData(std.LabelData{
+							Font:      c.textFont,
+							FontSize:  opt.V(float32(48)),
+							FontColor: opt.V(ui.Yellow()),
+							Text:      "Tap your scope when ready",
+						})
+					}))
+
+					for _, active := range c.globalState.Session.Players.Members() {
+						if !active.Approved || active.State == PlayerConnecting {
+							continue
+						}
+						co.WithChild("waiting-"+active.ID, co.New(std.Label, func() {
+							color := ui.RGB(0xAA, 0xAA, 0xAA)
+							if active.State == PlayerReady {
+								color = ui.Green()
+							}
+							co.WithData(std.LabelData{
+								Font:      c.textFont,
+								FontSize:  opt.V(float32(28)),
+								FontColor: opt.V(color),
+								Text:      fmt.Sprintf("%s: %s", active.Name(), active.State),
+							})
+						}))
+					}
+				}))
+
+			case rules.ModeCalibration:
 				// Show target crosshair
-				var targetX, targetY int
-				var targetText string
-				centerX := (c.screenWidth - MarkerSize) / 2
-				centerY := (c.screenHeight - MarkerSize) / 2
-				switch c.calibIndex {
-				case 0:
-					targetX, targetY = -centerX/2, -centerY/2
-					targetText = "Shoot TOP-LEFT"
-				case 1:
-					targetX, targetY = centerX/2, -centerY/2
-					targetText = "Shoot TOP-RIGHT"
-				case 2:
-					targetX, targetY = centerX/2, centerY/2
-					targetText = "Shoot BOTTOM-RIGHT"
-				case 3:
-					targetX, targetY = -centerX/2, centerY/2
-					targetText = "Shoot BOTTOM-LEFT"
-				}
+				pattern := c.match.CalibrationPattern()
+				index := min(c.match.CalibrationIndex(), len(pattern)-1)
+				target := c.match.ScreenPosition(pattern[index])
+				targetX := int(target.X) - c.screenWidth/2
+				targetY := int(target.Y) - c.screenHeight/2
+				targetText := fmt.Sprintf("Shoot %s (%d/%d)", calibrationLabel(pattern[index]), index+1, len(pattern))
 
 				co.WithChild("calib-target", co.New(std.Element, func() {
 					co.WithLayoutData(layout.Data{
@@ -664,13 +977,28 @@ func (c *playScreenComponent) Render() co.Instance {
 					})
 				}))
 
-			case PlayModeCountdown:
+				if c.calibNotice != "" {
+					co.WithChild("calib-notice", co.New(std.Label, func() {
+						co.WithLayoutData(layout.Data{
+							HorizontalCenter: opt.V(0),
+							VerticalCenter:   opt.V(100),
+						})
+						co.WithData(std.LabelData{
+							Font:      c.textFont,
+							FontSize:  opt.V(float32(24)),
+							FontColor: opt.V(ui.Red()),
+							Text:      c.calibNotice,
+						})
+					}))
+				}
+
+			case rules.ModeCountdown:
 				co.WithChild("countdown-text", co.New(std.Label, func() {
 					co.WithLayoutData(layout.Data{
 						HorizontalCenter: opt.V(0),
 						VerticalCenter:   opt.V(0),
 					})
-					seconds := int(c.modeTime.Seconds()) + 1
+					seconds := int(c.match.Remaining().Seconds()) + 1
 					co.WithData(std.LabelData{
 						Font:      c.textFont,
 						FontSize:  opt.V(float32(128)),
@@ -679,7 +1007,47 @@ func (c *playScreenComponent) Render() co.Instance {
 					})
 				}))
 
-			case PlayModePlaying:
+				co.WithChild("recalibrate-hint", co.New(std.Label, func() {
+					co.WithLayoutData(layout.Data{
+						HorizontalCenter: opt.V(0),
+						VerticalCenter:   opt.V(110),
+					})
+					co.WithData(std.LabelData{
+						Font:      c.textFont,
+						FontSize:  opt.V(float32(24)),
+						FontColor: opt.V(ui.White()),
+						Text:      "Press R to recalibrate",
+					})
+				}))
+
+				co.WithChild("calib-residuals", co.New(std.Container, func() {
+					co.WithLayoutData(layout.Data{
+						Bottom:           opt.V(20),
+						HorizontalCenter: opt.V(0),
+					})
+					co.WithData(std.ContainerData{
+						BackgroundColor: opt.V(ui.RGBA(0, 0, 0, 120)),
+						Padding:         ui.Spacing{Left: 15, Right: 15, Top: 10, Bottom: 10},
+						Layout: layout.Vertical(layout.VerticalSettings{
+							ContentSpacing: 5,
+						}),
+					})
+					for _, player := range c.match.Players() {
+						if !player.Active || !player.IsCalibrated() {
+							continue
+						}
+						co.WithChild("residual-"+player.ID, co.New(std.Label, func() {
+							co.WithData(std.LabelData{
+								Font:      c.textFont,
+								FontSize:  opt.V(float32(20)),
+								FontColor: opt.V(ui.White()),
+								Text:      c.residualText(player),
+							})
+						}))
+					}
+				}))
+
+			case rules.ModePlaying:
 				// HUD: Timer
 				co.WithChild("hud-timer", co.New(std.Container, func() {
 					co.WithLayoutData(layout.Data{
@@ -700,7 +1068,7 @@ func (c *playScreenComponent) Render() co.Instance {
 							Font:      c.textFont,
 							FontSize:  opt.V(float32(32)),
 							FontColor: opt.V(ui.White()),
-							Text:      fmt.Sprintf("TIME: %d", int(c.modeTime.Seconds())),
+							Text:      fmt.Sprintf("TIME: %d", int(c.match.Remaining().Seconds())),
 						})
 					}))
 				}))
@@ -719,22 +1087,10 @@ func (c *playScreenComponent) Render() co.Instance {
 						}),
 					})
 
-					for id, active := range c.globalState.Actives {
-						if time.Since(active.Time) > 5*time.Second {
-							continue
-						}
-						co.WithChild("score-"+id, co.New(std.Label, func() {
-							co.WithData(std.LabelData{
-								Font:      c.textFont,
-								FontSize:  opt.V(float32(20)),
-								FontColor: opt.V(ui.White()),
-								Text:      fmt.Sprintf("%s: %d", active.Info.Name, active.Score),
-							})
-						}))
-					}
+					c.scoreLabels(20, true)
 				}))
 
-			case PlayModeGameOver:
+			case rules.ModeGameOver:
 				co.WithChild("gameover-box", co.New(std.Container, func() {
 					co.WithLayoutData(layout.Data{
 						HorizontalCenter: opt.V(0),
@@ -766,14 +1122,24 @@ func (c *playScreenComponent) Render() co.Instance {
 								ContentSpacing:   5,
 							}),
 						})
-						for _, id := range slices.Sorted(maps.Keys(c.globalState.Actives)) {
-							active := c.globalState.Actives[id]
-							co.WithChild("score-"+id, co.New(std.Label, func() {
+						c.scoreLabels(24, false)
+					}))
+
+					// Stats List
+					co.WithChild("stats", co.New(std.Element, func() {
+						co.WithData(std.ElementData{
+							Layout: layout.Vertical(layout.VerticalSettings{
+								ContentAlignment: layout.HorizontalAlignmentLeft,
+								ContentSpacing:   5,
+							}),
+						})
+						for _, player := range c.match.Players() {
+							co.WithChild("stats-"+player.ID, co.New(std.Label, func() {
 								co.WithData(std.LabelData{
 									Font:      c.textFont,
-									FontSize:  opt.V(float32(24)),
-									FontColor: opt.V(ui.White()),
-									Text:      fmt.Sprintf("%s: %d", active.Info.Name, active.Score),
+									FontSize:  opt.V(float32(18)),
+									FontColor: opt.V(ui.RGB(0xAA, 0xAA, 0xAA)),
+									Text:      statsLabel(player),
 								})
 							}))
 						}
@@ -791,13 +1157,19 @@ func (c *playScreenComponent) Render() co.Instance {
 							})
 							co.WithCallbackData(std.ButtonCallbackData{
 								OnClick: func() {
-									c.ResetScores()
-									c.mode = PlayModeCountdown
-									c.modeTime = 3 * time.Second
+									c.match.Restart()
 									c.Invalidate()
 								},
 							})
 						}))
+						co.WithChild("recalibrate-btn", co.New(std.Button, func() {
+							co.WithData(std.ButtonData{
+								Text: "RECALIBRATE",
+							})
+							co.WithCallbackData(std.ButtonCallbackData{
+								OnClick: c.onRecalibrate,
+							})
+						}))
 						co.WithChild("exit-btn", co.New(std.Button, func() {
 							co.WithData(std.ButtonData{
 								Text: "EXIT",
@@ -814,16 +1186,15 @@ func (c *playScreenComponent) Render() co.Instance {
 		}))
 
 		// Player Markers (Only visible during Calibration, Countdown, and Playing)
-		if c.mode != PlayModeGameOver {
-			for _, id := range slices.Sorted(maps.Keys(c.globalState.Actives)) {
-				active := c.globalState.Actives[id]
-				if time.Since(active.Time) > 5*time.Second {
+		if c.match.Mode() != rules.ModeGameOver {
+			for _, player := range c.match.Players() {
+				if !player.Active {
 					continue
 				}
-				pos := active.Calibrate()
-				x := int(pos.X*float64(c.screenWidth-MarkerSize) + MarkerSize/2)
-				y := int(pos.Y*float64(c.screenHeight-MarkerSize) + MarkerSize/2)
-				co.WithChild("player-"+id, co.New(std.Element, func() {
+				pos := c.match.ScreenPosition(player.Position())
+				x := int(pos.X)
+				y := int(pos.Y)
+				co.WithChild("player-"+player.ID, co.New(std.Element, func() {
 					co.WithLayoutData(layout.Data{
 						HorizontalCenter: opt.V(int(x) - c.screenWidth/2),
 						Top:              opt.V(int(y) - 10),
@@ -836,9 +1207,15 @@ func (c *playScreenComponent) Render() co.Instance {
 
 					co.WithChild("dot", co.New(std.Container, func() {
 						color := ui.Green()
-						if active.Info.Fire {
+						if player.Fire {
 							color = ui.Red()
 						}
+						if teamColor, ok := TeamColor(player.Team); ok {
+							color = teamColor
+							if player.Fire {
+								color = ui.White()
+							}
+						}
 						co.WithLayoutData(layout.Data{
 							Width:  opt.V(20),
 							Height: opt.V(20),
@@ -853,7 +1230,7 @@ func (c *playScreenComponent) Render() co.Instance {
 							Font:      c.textFont,
 							FontSize:  opt.V(float32(32)),
 							FontColor: opt.V(ui.White()),
-							Text:      active.Info.Name,
+							Text:      player.Name,
 						})
 					}))
 				}))
@@ -938,22 +1315,5 @@ func (c *playScreenComponent) createCamera(scene *graphics.Scene) *graphics.Came
 	return result
 }
 
-func (c *playScreenComponent) ResetAll() {
-	c.calibIndex = 0
-	for id, active := range c.globalState.Actives {
-		active.Score = 0
-		active.Calibrated = 0
-		active.Calibration = [4]schema.Point{}
-		c.globalState.Actives[id] = active
-	}
-}
-
-func (c *playScreenComponent) ResetScores() {
-	for id, active := range c.globalState.Actives {
-		active.Score = 0
-		c.globalState.Actives[id] = active
-	}
-}
-
 // Temporary global storage for data across views
 var playSceneData *PlayData
@@ -0,0 +1,456 @@
+package ui
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nobonobo/gun-shooter/rules"
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+const (
+	// IdleTimeout はメッセージが途絶えたプレイヤーを Idle とみなすまでの時間。
+	IdleTimeout = 5 * time.Second
+	// ConnectTimeout は接続したまま Hello が届かないスコープを諦めるまでの時間。
+	ConnectTimeout = 10 * time.Second
+	// DisconnectRetention は切断されたプレイヤーを残しておく時間。
+	// この間に同じ ID とトークンで再接続すれば、スコアやキャリブレーションを引き継げる。
+	DisconnectRetention = 60 * time.Second
+)
+
+// PlayerState はホストから見たスコープの状態。
+//
+//	Connecting → Joined → Ready
+//	Disconnected → Connecting  同じ ID で再接続した。Hello のトークンが合えば元の状態に戻る
+//	Joined/Ready ⇄ Idle       メッセージが IdleTimeout の間途絶えると Idle
+//	* → Disconnected          データチャネルが閉じた
+//	* → Left                  Leave を受け取った、またはホストが外した
+type PlayerState int
+
+const (
+	PlayerConnecting PlayerState = iota
+	PlayerJoined
+	PlayerReady
+	PlayerIdle
+	PlayerDisconnected
+	PlayerLeft
+)
+
+func (s PlayerState) String() string {
+	switch s {
+	case PlayerConnecting:
+		return "connecting"
+	case PlayerJoined:
+		return "joined"
+	case PlayerReady:
+		return "ready"
+	case PlayerIdle:
+		return "idle"
+	case PlayerDisconnected:
+		return "disconnected"
+	case PlayerLeft:
+		return "left"
+	default:
+		return "unknown"
+	}
+}
+
+// Present はプレイに加わっている状態かどうか。
+func (s PlayerState) Present() bool {
+	return s == PlayerJoined || s == PlayerReady
+}
+
+// PlayerStateChangedEvent はプレイヤーの状態が変わるたびに mvc.EventBus に流れる。
+type PlayerStateChangedEvent struct {
+	ID   string
+	Name string
+	From PlayerState
+	To   PlayerState
+}
+
+// Registry は接続中のスコープを状態とともに管理する。
+// UI スレッドが所有し、ピアのコールバックからは InputQueue を介して更新する。
+type Registry struct {
+	members map[string]*ActiveMember
+	notify  func(PlayerStateChangedEvent)
+}
+
+// NewRegistry は状態が変わるたびに notify を呼ぶ Registry を作る。
+func NewRegistry(notify func(PlayerStateChangedEvent)) *Registry {
+	if notify == nil {
+		notify = func(PlayerStateChangedEvent) {}
+	}
+	return &Registry{
+		members: make(map[string]*ActiveMember),
+		notify:  notify,
+	}
+}
+
+// setState は a の状態を変え、通知するイベントを返す。変わらなければ ok は false。
+func setState(a *ActiveMember, to PlayerState) (e PlayerStateChangedEvent, ok bool) {
+	if a.State == to {
+		return e, false
+	}
+	e = PlayerStateChangedEvent{ID: a.ID, Name: a.Name(), From: a.State, To: to}
+	a.State = to
+	return e, true
+}
+
+// transition は id の状態を fn で変え、変わっていれば通知する。
+func (r *Registry) transition(id string, fn func(a *ActiveMember) PlayerState) bool {
+	a, ok := r.members[id]
+	if !ok {
+		return false
+	}
+	e, changed := setState(a, fn(a))
+	if a.State == PlayerLeft {
+		delete(r.members, id)
+	}
+	if changed {
+		r.notify(e)
+	}
+	return true
+}
+
+// Connect はピアが接続してきたことを記録する。Hello が届くまでは Connecting。
+// 同じ ID のプレイヤーが残っていれば、Resume できるよう中身を残してピアだけ差し替える。
+func (r *Registry) Connect(peer Peer) {
+	id := peer.ID()
+	if r.transition(id, func(a *ActiveMember) PlayerState {
+		a.Peer, a.Link, a.Time = peer, nil, time.Now()
+		return PlayerConnecting
+	}) {
+		return
+	}
+	r.members[id] = &ActiveMember{ID: id, Time: time.Now(), Peer: peer}
+	r.notify(PlayerStateChangedEvent{ID: id, From: PlayerLeft, To: PlayerConnecting})
+}
+
+// Join は Hello を受け取ったスコープを Joined にする。
+// member の ID, State, Time, Peer は Registry が埋める。
+// 承認やミュートを消さないよう、Connecting のときだけ参加させる。
+func (r *Registry) Join(id string, member ActiveMember) bool {
+	joined := false
+	r.transition(id, func(a *ActiveMember) PlayerState {
+		if a.State != PlayerConnecting {
+			return a.State
+		}
+		joined = true
+		member.ID, member.State, member.Time, member.Peer = id, a.State, time.Now(), a.Peer
+		*a = member
+		return PlayerJoined
+	})
+	return joined
+}
+
+// Resume は token が一致すれば、再接続してきたスコープを前回の状態に戻す。
+// スコア、キャリブレーション、承認、名前の変更などはそのまま引き継ぐ。
+func (r *Registry) Resume(id, token string, link *Link) bool {
+	if token == "" {
+		return false
+	}
+	resumed := false
+	r.transition(id, func(a *ActiveMember) PlayerState {
+		if a.State != PlayerConnecting || a.Token != token {
+			return a.State
+		}
+		resumed = true
+		a.Link, a.Time = link, time.Now()
+		// 経路が変わっているかもしれないので時計のずれは測り直す
+		a.Clock = &schema.ClockSync{}
+		a.Stats = NetStats{}
+		a.Shots = nil
+		return a.presentState()
+	})
+	return resumed
+}
+
+// Touch はメッセージが届いたことを記録し、Idle なら元の状態に戻す。
+// Hello の前なら false を返す。
+func (r *Registry) Touch(id string) bool {
+	ok := false
+	r.transition(id, func(a *ActiveMember) PlayerState {
+		a.Time = time.Now()
+		switch a.State {
+		case PlayerIdle:
+			ok = true
+			return a.presentState()
+		case PlayerJoined, PlayerReady:
+			ok = true
+		}
+		return a.State
+	})
+	return ok
+}
+
+// Ready はプレイヤーが準備できたことを記録する。
+func (r *Registry) Ready(id string) {
+	r.transition(id, func(a *ActiveMember) PlayerState {
+		if !a.State.Present() && a.State != PlayerIdle {
+			return a.State
+		}
+		a.Ready = true
+		return PlayerReady
+	})
+}
+
+// ResetReady は全員の準備を取り消す。新しい Match を始めるときに呼ぶ。
+func (r *Registry) ResetReady() {
+	for _, a := range r.Members() {
+		r.transition(a.ID, func(a *ActiveMember) PlayerState {
+			a.Ready = false
+			if a.State == PlayerReady {
+				return PlayerJoined
+			}
+			return a.State
+		})
+	}
+}
+
+// Disconnect はデータチャネルが閉じたプレイヤーを Disconnected にする。
+// 既に別のピアで再接続している場合、古いピアの切断は無視する。
+func (r *Registry) Disconnect(id string, peer Peer) {
+	r.transition(id, func(a *ActiveMember) PlayerState {
+		if a.State == PlayerLeft || a.Peer != peer {
+			return a.State
+		}
+		// DisconnectRetention は切断した時刻から数える
+		a.Time = time.Now()
+		return PlayerDisconnected
+	})
+}
+
+// Remove はプレイヤーを Left にして一覧から外し、外す前の内容を返す。
+func (r *Registry) Remove(id string) (ActiveMember, bool) {
+	var removed ActiveMember
+	ok := r.transition(id, func(a *ActiveMember) PlayerState {
+		removed = *a
+		return PlayerLeft
+	})
+	return removed, ok
+}
+
+// Update は UI スレッドからの操作 (承認、ミュート、名前の変更など) を反映する。
+// 状態と最終受信時刻は変えない。
+func (r *Registry) Update(id string, fn func(a *ActiveMember)) bool {
+	a, ok := r.members[id]
+	if ok {
+		fn(a)
+	}
+	return ok
+}
+
+func (r *Registry) Get(id string) (ActiveMember, bool) {
+	a, ok := r.members[id]
+	if !ok {
+		return ActiveMember{}, false
+	}
+	return *a, true
+}
+
+// Members は全メンバーのコピーを ID 順に返す。
+func (r *Registry) Members() []ActiveMember {
+	result := make([]ActiveMember, 0, len(r.members))
+	for _, id := range slices.Sorted(maps.Keys(r.members)) {
+		result = append(result, *r.members[id])
+	}
+	return result
+}
+
+// TakeShots は id の未処理の射撃を取り出す。
+func (r *Registry) TakeShots(id string) []Shot {
+	a, ok := r.members[id]
+	if !ok {
+		return nil
+	}
+	shots := a.Shots
+	a.Shots = nil
+	return shots
+}
+
+// AllReady は承認済みで参加中のプレイヤーが 1 人以上いて、全員が Ready かどうか。
+func (r *Registry) AllReady() bool {
+	ready := 0
+	for _, a := range r.members {
+		if !a.Approved || a.Spectator {
+			continue
+		}
+		switch a.State {
+		case PlayerJoined:
+			return false
+		case PlayerReady:
+			ready++
+		}
+	}
+	return ready > 0
+}
+
+// AssignTeams は観戦以外のメンバーを teams 個のチームに均等に分け直す。
+// 今のチームはなるべく残し、人数をそろえるのに必要な人だけを移す。
+// teams が 0 なら全員をチームから外す。
+func (r *Registry) AssignTeams(teams int) {
+	current := map[string]int{}
+	for _, a := range r.members {
+		if !a.Spectator {
+			current[a.ID] = a.Team
+		}
+	}
+	for id, team := range rules.BalanceTeams(current, teams) {
+		r.members[id].Team = team
+	}
+}
+
+// smallestTeam は後から参加したプレイヤーを入れるチームとして、人数の最も少ないチームを返す。
+func (r *Registry) smallestTeam(teams int) int {
+	if teams <= 0 {
+		return 0
+	}
+	counts := make([]int, teams+1)
+	for _, a := range r.members {
+		if !a.Spectator && a.Team > 0 && a.Team <= teams {
+			counts[a.Team]++
+		}
+	}
+	team := 1
+	for t := 2; t <= teams; t++ {
+		if counts[t] < counts[team] {
+			team = t
+		}
+	}
+	return team
+}
+
+// Sweep は now の時点でタイムアウトしたプレイヤーの状態を進める。
+func (r *Registry) Sweep(now time.Time) {
+	var events []PlayerStateChangedEvent
+	for id, a := range r.members {
+		elapsed := now.Sub(a.Time)
+		to := a.State
+		switch a.State {
+		case PlayerConnecting:
+			if elapsed > ConnectTimeout {
+				to = PlayerDisconnected
+				a.Time = now
+			}
+		case PlayerJoined, PlayerReady:
+			if elapsed > IdleTimeout {
+				to = PlayerIdle
+			}
+		case PlayerDisconnected:
+			if elapsed > DisconnectRetention {
+				to = PlayerLeft
+				delete(r.members, id)
+			}
+		}
+		if e, ok := setState(a, to); ok {
+			events = append(events, e)
+		}
+	}
+	for _, e := range events {
+		r.notify(e)
+	}
+}
+
+// Apply はスコープから届いたメッセージを反映する。
+// Hello で PIN を確認できるまで、他のメッセージは受け付けない。
+func (r *Registry) Apply(e PeerEvent, settings *Settings) {
+	id := e.ID
+	if hello, ok := e.Message.(schema.Hello); ok {
+		if settings.PIN != "" && hello.PIN != settings.PIN {
+			log.Println("wrong pin:", id, hello.Name)
+			r.Remove(id)
+			deny(e.Peer, e.Link, "wrong PIN")
+			return
+		}
+		if r.Resume(id, hello.Token, e.Link) {
+			log.Println("resumed:", id, hello.Name, "codec:", hello.Codec, "seq:", e.Envelope.Seq)
+			r.observe(e)
+			welcome(e.Link, hello.Token, true)
+			return
+		}
+		token := uuid.NewString()
+		spectator := hello.Role == schema.RoleSpectator
+		team := 0
+		if !spectator {
+			team = r.smallestTeam(settings.Teams)
+		}
+		if !r.Join(id, ActiveMember{
+			Info: schema.Info{
+				ID:     hello.ID,
+				Name:   hello.Name,
+				Device: hello.Device,
+			},
+			Clock:     &schema.ClockSync{},
+			Link:      e.Link,
+			Token:     token,
+			Spectator: spectator,
+			Team:      team,
+			// 観戦は試合に影響しないので承認を待たない
+			Approved: spectator || !settings.RequireApproval,
+		}) {
+			// 参加済みのスコープが Hello を送り直しても、ホストが決めたことは変えない
+			log.Println("hello ignored:", id, hello.Name)
+			return
+		}
+		log.Println("hello:", id, hello.Name, "role:", hello.Role, "codec:", hello.Codec, "seq:", e.Envelope.Seq)
+		r.observe(e)
+		welcome(e.Link, token, false)
+		return
+	}
+	if !r.Touch(id) {
+		log.Println("message before hello:", id, e.Envelope.Type)
+		return
+	}
+	r.observe(e)
+	if active, _ := r.Get(id); active.Spectator {
+		switch e.Message.(type) {
+		case schema.Heartbeat, schema.Pong, schema.Leave:
+		default:
+			log.Println("input from spectator ignored:", id, e.Envelope.Type)
+			return
+		}
+	}
+	switch m := e.Message.(type) {
+	case schema.Ready:
+		r.Ready(id)
+	case schema.Leave:
+		if active, ok := r.Remove(id); ok {
+			log.Println("left:", id, active.Name())
+		}
+	default:
+		r.Update(id, func(active *ActiveMember) {
+			active.apply(m)
+		})
+	}
+}
+
+// observe は e の到着を受信状況に記録する。
+func (r *Registry) observe(e PeerEvent) {
+	r.Update(e.ID, func(a *ActiveMember) {
+		a.Stats.Observe(e.Envelope.Seq, e.Received, e.Envelope.Type == schema.TypeAim)
+	})
+}
+
+// welcome は参加を受け付けたことと、再接続に使うトークンをスコープに伝える。
+func welcome(link *Link, token string, resumed bool) {
+	if err := link.Send(schema.Welcome{Token: token, Resumed: resumed}); err != nil {
+		log.Println("failed to send welcome:", err)
+	}
+}
+
+// deny は参加を断ったことをスコープに伝えてから接続を切る。
+func deny(peer Peer, link *Link, reason string) {
+	if err := link.Send(schema.Denied{Reason: reason}); err != nil {
+		log.Println("failed to send denied:", peer.ID(), err)
+	}
+	// Denied が届くのを少し待ってから切る
+	time.AfterFunc(500*time.Millisecond, func() {
+		if err := peer.PeerConnection().Close(); err != nil {
+			log.Println("failed to close peer:", peer.ID(), err)
+		}
+	})
+}
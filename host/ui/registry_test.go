@@ -0,0 +1,461 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+type fakePeer struct {
+	id string
+}
+
+func (p *fakePeer) ID() string                             { return p.id }
+func (p *fakePeer) PeerConnection() *webrtc.PeerConnection { return nil }
+
+// testLink は開いていないチャネルの Link。送信は失敗するだけで何も起きない。
+func testLink() *Link {
+	return NewLink(&webrtc.DataChannel{})
+}
+
+type registryTest struct {
+	t        *testing.T
+	registry *Registry
+	settings *Settings
+	peers    map[string]*fakePeer
+	events   []PlayerStateChangedEvent
+}
+
+func newRegistryTest(t *testing.T) *registryTest {
+	rt := &registryTest{t: t, settings: &Settings{}, peers: map[string]*fakePeer{}}
+	rt.registry = NewRegistry(func(e PlayerStateChangedEvent) {
+		rt.events = append(rt.events, e)
+	})
+	return rt
+}
+
+func (rt *registryTest) connect(id string) {
+	peer := &fakePeer{id: id}
+	rt.peers[id] = peer
+	rt.registry.Connect(peer)
+}
+
+func (rt *registryTest) send(id string, msg schema.Message) {
+	rt.registry.Apply(PeerEvent{
+		Kind:     PeerMessage,
+		ID:       id,
+		Peer:     rt.peers[id],
+		Link:     testLink(),
+		Envelope: schema.Envelope{Type: msg.MessageType(), Version: schema.ProtocolVersion},
+		Message:  msg,
+		Received: time.Now(),
+	}, rt.settings)
+}
+
+func (rt *registryTest) hello(id, token string) {
+	rt.send(id, schema.Hello{ID: id, Name: "name-" + id, Token: token})
+}
+
+// join は id を接続させて Hello まで済ませ、受け取ったトークンを返す。
+func (rt *registryTest) join(id string) string {
+	rt.connect(id)
+	rt.hello(id, "")
+	a, ok := rt.registry.Get(id)
+	if !ok {
+		rt.t.Fatalf("%s not joined", id)
+	}
+	return a.Token
+}
+
+func (rt *registryTest) state(id string) (PlayerState, bool) {
+	a, ok := rt.registry.Get(id)
+	return a.State, ok
+}
+
+func TestRegistryTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   func(rt *registryTest)
+		want    PlayerState
+		removed bool
+	}{
+		{
+			name:  "connected",
+			steps: func(rt *registryTest) { rt.connect("p1") },
+			want:  PlayerConnecting,
+		},
+		{
+			name: "message before hello",
+			steps: func(rt *registryTest) {
+				rt.connect("p1")
+				rt.send("p1", schema.Ready{})
+			},
+			want: PlayerConnecting,
+		},
+		{
+			name:  "hello",
+			steps: func(rt *registryTest) { rt.join("p1") },
+			want:  PlayerJoined,
+		},
+		{
+			name: "ready",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Ready{})
+			},
+			want: PlayerReady,
+		},
+		{
+			name: "idle",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.registry.Sweep(time.Now().Add(IdleTimeout + time.Second))
+			},
+			want: PlayerIdle,
+		},
+		{
+			name: "back from idle keeps ready",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Ready{})
+				rt.registry.Sweep(time.Now().Add(IdleTimeout + time.Second))
+				rt.send("p1", schema.Heartbeat{})
+			},
+			want: PlayerReady,
+		},
+		{
+			name: "hello timeout",
+			steps: func(rt *registryTest) {
+				rt.connect("p1")
+				rt.registry.Sweep(time.Now().Add(ConnectTimeout + time.Second))
+			},
+			want: PlayerDisconnected,
+		},
+		{
+			name: "disconnected",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.registry.Disconnect("p1", rt.peers["p1"])
+			},
+			want: PlayerDisconnected,
+		},
+		{
+			name: "disconnect of a replaced peer is ignored",
+			steps: func(rt *registryTest) {
+				token := rt.join("p1")
+				old := rt.peers["p1"]
+				rt.registry.Disconnect("p1", old)
+				rt.connect("p1")
+				rt.hello("p1", token)
+				rt.registry.Disconnect("p1", old)
+			},
+			want: PlayerJoined,
+		},
+		{
+			name: "retention expired",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.registry.Disconnect("p1", rt.peers["p1"])
+				rt.registry.Sweep(time.Now().Add(DisconnectRetention + time.Second))
+			},
+			removed: true,
+		},
+		{
+			name: "leave",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Leave{})
+			},
+			removed: true,
+		},
+		{
+			name: "resume",
+			steps: func(rt *registryTest) {
+				token := rt.join("p1")
+				rt.send("p1", schema.Ready{})
+				rt.registry.Disconnect("p1", rt.peers["p1"])
+				rt.connect("p1")
+				rt.hello("p1", token)
+			},
+			want: PlayerReady,
+		},
+		{
+			name: "resume with a stale token joins again",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Ready{})
+				rt.registry.Disconnect("p1", rt.peers["p1"])
+				rt.connect("p1")
+				rt.hello("p1", "stale")
+			},
+			want: PlayerJoined,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newRegistryTest(t)
+			tt.steps(rt)
+			state, ok := rt.state("p1")
+			if tt.removed {
+				if ok {
+					t.Errorf("state = %v, want removed", state)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("player removed")
+			}
+			if state != tt.want {
+				t.Errorf("state = %v, want %v", state, tt.want)
+			}
+			// 通知の From と To は途切れずにつながっている
+			from := PlayerLeft
+			for _, e := range rt.events {
+				if e.From != from {
+					t.Errorf("event %v -> %v, want from %v", e.From, e.To, from)
+				}
+				from = e.To
+			}
+			if from != state {
+				t.Errorf("last event ends in %v, want %v", from, state)
+			}
+		})
+	}
+}
+
+func TestRegistryResume(t *testing.T) {
+	rt := newRegistryTest(t)
+	token := rt.join("p1")
+	rt.registry.Update("p1", func(a *ActiveMember) {
+		a.Approved = false
+		a.Rename = "renamed"
+	})
+	rt.registry.Disconnect("p1", rt.peers["p1"])
+	rt.connect("p1")
+	rt.hello("p1", token)
+
+	a, _ := rt.registry.Get("p1")
+	if a.Token != token || a.Rename != "renamed" || a.Approved {
+		t.Errorf("resumed member = %+v, want the previous token, name and approval", a)
+	}
+
+	// 古いトークンでは別のプレイヤーとして参加し直し、前回の状態は引き継がない
+	rt.registry.Disconnect("p1", rt.peers["p1"])
+	rt.connect("p1")
+	rt.hello("p1", "stale")
+	a, _ = rt.registry.Get("p1")
+	if a.Token == token || a.Token == "" || a.Rename != "" || !a.Approved {
+		t.Errorf("member after stale token = %+v, want a fresh join", a)
+	}
+}
+
+func TestRegistryHelloAfterJoin(t *testing.T) {
+	rt := newRegistryTest(t)
+	token := rt.join("p1")
+	rt.send("p1", schema.Ready{})
+	rt.send("p1", schema.Fire{ShotID: 5})
+	rt.registry.Update("p1", func(a *ActiveMember) {
+		a.Muted = true
+		a.Approved = false
+		a.Rename = "renamed"
+		a.Team = 2
+	})
+
+	// Resume できない Hello を送り直しても参加し直さない
+	rt.hello("p1", "")
+	rt.hello("p1", token)
+	a, _ := rt.registry.Get("p1")
+	if a.State != PlayerReady || a.Token != token || !a.Muted || a.Approved ||
+		a.Rename != "renamed" || a.Team != 2 || a.LastShot != 5 {
+		t.Errorf("member after hello = %+v, want the state before the hello", a)
+	}
+}
+
+func TestRegistryKeepsHostDecisions(t *testing.T) {
+	messages := []schema.Message{
+		schema.Hello{Name: "again"},
+		schema.Hello{Name: "again", Role: schema.RoleSpectator},
+		schema.Hello{Name: "again", Role: schema.RolePlayer},
+		schema.Ready{},
+		schema.Aim{X: 0.5, Y: 0.5},
+		schema.Fire{ShotID: 1, X: 0.5, Y: 0.5},
+		schema.Heartbeat{},
+		schema.Pong{},
+		schema.Welcome{Token: "forged"},
+	}
+	for _, msg := range messages {
+		t.Run(string(msg.MessageType()), func(t *testing.T) {
+			rt := newRegistryTest(t)
+			rt.settings.RequireApproval = true
+			rt.settings.Teams = 2
+			// p1 はホストが承認してチームを移したプレイヤー、p2 はホストが観戦に回したスコープ
+			rt.join("p1")
+			rt.registry.Update("p1", func(a *ActiveMember) {
+				a.Approved = true
+				a.Team = 2
+				a.Rename = "renamed"
+			})
+			rt.join("p2")
+			rt.registry.Update("p2", func(a *ActiveMember) {
+				a.Approved = true
+				a.Spectator = true
+				a.Team = 0
+			})
+			want := map[string]ActiveMember{}
+			for _, id := range []string{"p1", "p2"} {
+				want[id], _ = rt.registry.Get(id)
+				rt.send(id, msg)
+			}
+			for id, w := range want {
+				a, _ := rt.registry.Get(id)
+				if a.Approved != w.Approved || a.Spectator != w.Spectator || a.Team != w.Team ||
+					a.Rename != w.Rename || a.Muted != w.Muted || a.Token != w.Token {
+					t.Errorf("%s after %T = %+v, want the host's decisions in %+v", id, msg, a, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryDuplicateShots(t *testing.T) {
+	rt := newRegistryTest(t)
+	rt.join("p1")
+	for _, id := range []uint32{1, 2, 2, 1, 3, 3} {
+		rt.send("p1", schema.Fire{ShotID: id, X: 0.5, Y: 0.5})
+	}
+	var ids []uint32
+	for _, shot := range rt.registry.TakeShots("p1") {
+		ids = append(ids, shot.ID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("shots = %v, want [1 2 3]", ids)
+	}
+	if shots := rt.registry.TakeShots("p1"); len(shots) != 0 {
+		t.Errorf("shots taken twice: %v", shots)
+	}
+
+	// 承認されていないプレイヤーの射撃は数えない
+	rt.settings.RequireApproval = true
+	rt.join("p2")
+	rt.send("p2", schema.Fire{ShotID: 1})
+	if shots := rt.registry.TakeShots("p2"); len(shots) != 0 {
+		t.Errorf("unapproved shots = %v, want none", shots)
+	}
+}
+
+func TestRegistryAllReady(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps func(rt *registryTest)
+		want  bool
+	}{
+		{
+			name:  "nobody",
+			steps: func(rt *registryTest) {},
+			want:  false,
+		},
+		{
+			name: "all ready",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.join("p2")
+				rt.send("p1", schema.Ready{})
+				rt.send("p2", schema.Ready{})
+			},
+			want: true,
+		},
+		{
+			name: "one joined",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.join("p2")
+				rt.send("p1", schema.Ready{})
+			},
+			want: false,
+		},
+		{
+			name: "idle player does not block",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Ready{})
+				rt.join("p2")
+				rt.registry.Update("p1", func(a *ActiveMember) { a.Time = time.Now().Add(time.Minute) })
+				rt.registry.Sweep(time.Now().Add(IdleTimeout + time.Second))
+			},
+			want: true,
+		},
+		{
+			name: "unapproved player does not block",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Ready{})
+				rt.settings.RequireApproval = true
+				rt.join("p2")
+			},
+			want: true,
+		},
+		{
+			name: "spectator does not count",
+			steps: func(rt *registryTest) {
+				rt.connect("s1")
+				rt.send("s1", schema.Hello{ID: "s1", Role: schema.RoleSpectator})
+			},
+			want: false,
+		},
+		{
+			name: "ResetReady",
+			steps: func(rt *registryTest) {
+				rt.join("p1")
+				rt.send("p1", schema.Ready{})
+				rt.registry.ResetReady()
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newRegistryTest(t)
+			tt.steps(rt)
+			if got := rt.registry.AllReady(); got != tt.want {
+				t.Errorf("AllReady = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryAssignTeams(t *testing.T) {
+	rt := newRegistryTest(t)
+	rt.settings.Teams = 2
+	for _, id := range []string{"p1", "p2", "p3"} {
+		rt.join(id)
+	}
+	rt.connect("s1")
+	rt.send("s1", schema.Hello{ID: "s1", Role: schema.RoleSpectator})
+	// 参加した順に人数の少ないチームへ入る
+	want := map[string]int{"p1": 1, "p2": 2, "p3": 1, "s1": 0}
+	for id, team := range want {
+		if a, _ := rt.registry.Get(id); a.Team != team {
+			t.Errorf("%s joined team %d, want %d", id, a.Team, team)
+		}
+	}
+
+	// 手で偏らせてから分け直すと、多すぎるチームから ID 順で後ろの 1 人だけを移す
+	rt.registry.Update("p2", func(a *ActiveMember) { a.Team = 1 })
+	rt.registry.AssignTeams(2)
+	want = map[string]int{"p1": 1, "p2": 1, "p3": 2, "s1": 0}
+	for id, team := range want {
+		if a, _ := rt.registry.Get(id); a.Team != team {
+			t.Errorf("%s balanced into team %d, want %d", id, a.Team, team)
+		}
+	}
+
+	rt.registry.AssignTeams(0)
+	for _, a := range rt.registry.Members() {
+		if a.Team != 0 {
+			t.Errorf("%s still in team %d", a.ID, a.Team)
+		}
+	}
+}
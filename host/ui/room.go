@@ -1,12 +1,13 @@
 package ui
 
 import (
-	"context"
-	"encoding/json"
+	"fmt"
 	"log"
+	"net/url"
 	"reflect"
+	"slices"
 	"sort"
-	"time"
+	"strings"
 
 	"github.com/mokiat/gog/opt"
 	"github.com/mokiat/lacking/game"
@@ -15,17 +16,22 @@ import (
 	"github.com/mokiat/lacking/ui/layout"
 	"github.com/mokiat/lacking/ui/mvc"
 	"github.com/mokiat/lacking/ui/std"
-	"github.com/pion/webrtc/v4"
 
 	"github.com/nobonobo/gun-shooter/host/ui/widget"
-	"github.com/nobonobo/gun-shooter/schema"
-	"github.com/nobonobo/rtcconnect/node"
+	"github.com/nobonobo/gun-shooter/rules"
 )
 
 var RoomScreen = mvc.EventListener(co.Define[*roomScreenComponent]())
 
-type RoomMembersUpdatedEvent struct {
-	Members []string
+// RoomMember はルーム画面のメンバー一覧の 1 行。
+type RoomMember struct {
+	ID        string
+	Name      string
+	State     PlayerState
+	Spectator bool
+	Approved  bool
+	Muted     bool
+	Team      int
 }
 
 type RoomScreenData struct {
@@ -42,11 +48,8 @@ type roomScreenComponent struct {
 
 	titleFont *ui.Font
 	textFont  *ui.Font
-	members   []string
-
-	host   *node.Node
-	ctx    context.Context
-	cancel context.CancelFunc
+	members   []RoomMember
+	renaming  string // 名前を編集中のメンバーの ID
 
 	globalState GlobalState
 	eventBus    *mvc.EventBus
@@ -67,83 +70,35 @@ func (c *roomScreenComponent) OnCreate() {
 	c.titleFont = co.OpenFont(c.Scope(), "ui:///roboto-bold.ttf")
 	c.textFont = co.OpenFont(c.Scope(), "ui:///roboto-regular.ttf")
 
-	c.host = node.NewHost(GetParam("id"))
-	c.ctx, c.cancel = context.WithCancel(context.Background())
-	go func() {
-		log.Println("listen start:", c.host.ID())
-		defer log.Println("listen stop:", c.host.ID())
-		if err := c.host.Listen(c.ctx); err != nil {
-			log.Println("failed to listen", err)
-		}
-	}()
-
-	c.host.OnConnected = func(peer *node.Node) {
-		id := peer.ID()
-		peer.PeerConnection().OnDataChannel(func(dc *webrtc.DataChannel) {
-			log.Println("data channel opened:", id)
-			dc.OnClose(func() {
-				log.Println("data channel closed:", id)
-			})
-			//cnt := 0
-			dc.OnMessage(func(msg webrtc.DataChannelMessage) {
-				var info *schema.Info
-				if err := json.Unmarshal(msg.Data, &info); err != nil {
-					log.Println("failed to unmarshal info:", err)
-					return
-				}
-				/*
-					cnt++
-					if cnt%1000 == 0 {
-						log.Println("data channel message:", id, info)
-					}
-				*/
-				old, ok := c.globalState.Actives[id]
-				if ok {
-					info.Fire = info.Fire || old.Info.Fire
-				}
-				active, ok := c.globalState.Actives[id]
-				if ok {
-					active.Info = info
-					active.Time = time.Now()
-					c.globalState.Actives[id] = active
-				} else {
-					c.globalState.Actives[id] = ActiveMember{
-						Time:  time.Now(),
-						Info:  info,
-						Score: 0,
-					}
-				}
-				c.UpdateMembers()
-			})
-		})
-	}
-	go func() {
-		ticker := time.NewTicker(time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-c.ctx.Done():
-				return
-			case <-ticker.C:
-				c.UpdateMembers()
-			}
-		}
-	}()
+	c.refreshMembers()
 }
 
-func (c *roomScreenComponent) UpdateMembers() {
-	members := []string{}
-	for _, active := range c.globalState.Actives {
-		if time.Since(active.Time) > 5*time.Second {
+// refreshMembers は Registry からメンバー一覧を作り直し、変わっていれば再描画する。
+// 名乗る前のスコープと退出したスコープは出さない。
+func (c *roomScreenComponent) refreshMembers() {
+	members := []RoomMember{}
+	for _, active := range c.globalState.Session.Players.Members() {
+		if active.State == PlayerConnecting || active.State == PlayerLeft {
 			continue
 		}
-		members = append(members, active.Info.Name)
+		members = append(members, RoomMember{
+			ID:        active.ID,
+			Name:      active.Name(),
+			State:     active.State,
+			Spectator: active.Spectator,
+			Approved:  active.Approved,
+			Muted:     active.Muted,
+			Team:      active.Team,
+		})
 	}
-	c.eventBus.Notify(RoomMembersUpdatedEvent{Members: members})
-}
-
-func (c *roomScreenComponent) OnDelete() {
-	c.cancel()
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	if reflect.DeepEqual(c.members, members) {
+		return
+	}
+	c.members = members
+	c.Invalidate() // 再描画を要求
 }
 
 func (c *roomScreenComponent) Render() co.Instance {
@@ -187,6 +142,57 @@ func (c *roomScreenComponent) Render() co.Instance {
 					})
 				}))
 
+				co.WithChild("calib-button", co.New(widget.Button, func() {
+					co.WithData(widget.ButtonData{
+						Text: fmt.Sprintf("Calib: %d pts", len(c.globalState.Settings.CalibrationPattern)),
+					})
+					co.WithCallbackData(widget.ButtonCallbackData{
+						OnClick: c.onCalibrationClicked,
+					})
+				}))
+
+				co.WithChild("settings-button", co.New(widget.Button, func() {
+					co.WithData(widget.ButtonData{
+						Text: "Match: " + c.globalState.Settings.Match.PresetName(),
+					})
+					co.WithCallbackData(widget.ButtonCallbackData{
+						OnClick: c.onSettingsClicked,
+					})
+				}))
+
+				co.WithChild("teams-button", co.New(widget.Button, func() {
+					text := "Teams: off"
+					if teams := c.globalState.Settings.Teams; teams > 0 {
+						text = fmt.Sprintf("Teams: %d", teams)
+					}
+					co.WithData(widget.ButtonData{
+						Text: text,
+					})
+					co.WithCallbackData(widget.ButtonCallbackData{
+						OnClick: c.onTeamsClicked,
+					})
+				}))
+
+				if c.globalState.Settings.Teams > 0 {
+					co.WithChild("balance-button", co.New(widget.Button, func() {
+						co.WithData(widget.ButtonData{
+							Text: "Balance",
+						})
+						co.WithCallbackData(widget.ButtonCallbackData{
+							OnClick: c.onBalanceClicked,
+						})
+					}))
+				}
+
+				co.WithChild("spectate-button", co.New(widget.Button, func() {
+					co.WithData(widget.ButtonData{
+						Text: "Spectate",
+					})
+					co.WithCallbackData(widget.ButtonCallbackData{
+						OnClick: c.onSpectateClicked,
+					})
+				}))
+
 				co.WithChild("back-button", co.New(widget.Button, func() {
 					co.WithData(widget.ButtonData{
 						Text: "Back",
@@ -223,10 +229,16 @@ func (c *roomScreenComponent) Render() co.Instance {
 					Layout: layout.Anchor(),
 				})
 				
-				link := BaseURL() + "scope/?dest=" + GetParam("id")
+				link := BaseURL() + "scope/?dest=" + c.globalState.Session.ID()
 				if c.flip {
 					link += "&flip=true"
 				}
+				if endpoint := SignalEndpoint(); endpoint != "" {
+					link += "&signal=" + url.QueryEscape(endpoint)
+				}
+				if pin := c.globalState.Settings.PIN; pin != "" {
+					link += "&pin=" + url.QueryEscape(pin)
+				}
 				
 				co.WithChild("qr-code", co.New(widget.QRCode, func() {
 					co.WithLayoutData(layout.Data{
@@ -283,7 +295,7 @@ func (c *roomScreenComponent) Render() co.Instance {
 				co.WithLayoutData(layout.Data{
 					Top:              opt.V(20),
 					HorizontalCenter: opt.V(170),
-					Width:            opt.V(320),
+					Width:            opt.V(480),
 					Height:           opt.V(320),
 				})
 				co.WithData(std.ContainerData{
@@ -303,14 +315,123 @@ func (c *roomScreenComponent) Render() co.Instance {
 				}))
 
 				// Dynamic Members
-				for _, name := range c.members {
-					co.WithChild("member-"+name, co.New(std.Label, func() {
-						co.WithData(std.LabelData{
-							Font:      c.textFont,
-							FontSize:  opt.V(float32(20)),
-							FontColor: opt.V(ui.RGB(0xAA, 0xAA, 0xAA)),
-							Text:      name,
+				for _, member := range c.members {
+					co.WithChild("member-"+member.ID, co.New(std.Element, func() {
+						co.WithData(std.ElementData{
+							Layout: layout.Horizontal(layout.HorizontalSettings{
+								ContentAlignment: layout.VerticalAlignmentCenter,
+								ContentSpacing:   10,
+							}),
 						})
+
+						if c.renaming == member.ID {
+							co.WithChild("rename-box", co.New(std.EditBox, func() {
+								co.WithLayoutData(layout.Data{
+									Width: opt.V(160),
+								})
+								co.WithData(std.EditBoxData{
+									Text:          member.Name,
+									CreateFocused: true,
+								})
+								co.WithCallbackData(std.EditBoxCallbackData{
+									OnSubmit: func(name string) { c.onRenameSubmitted(member.ID, name) },
+									OnReject: c.onRenameRejected,
+								})
+							}))
+						} else {
+							co.WithChild("name", co.New(std.Label, func() {
+								color := ui.RGB(0xAA, 0xAA, 0xAA)
+								switch {
+								case !member.Approved:
+									color = ui.RGB(0xE6, 0x7E, 0x22)
+								case !member.State.Present() || member.Muted:
+									color = ui.RGB(0x66, 0x66, 0x66)
+								case member.State == PlayerReady:
+									color = ui.RGB(0x2E, 0xCC, 0x71)
+								case member.Spectator:
+									color = ui.RGB(0x34, 0x98, 0xDB)
+								}
+								state := member.State.String()
+								if member.Spectator {
+									state = "spectator, " + state
+								}
+								co.WithData(std.LabelData{
+									Font:      c.textFont,
+									FontSize:  opt.V(float32(20)),
+									FontColor: opt.V(color),
+									Text:      fmt.Sprintf("%s (%s)", member.Name, state),
+								})
+							}))
+						}
+
+						// 未承認のメンバーには承認/拒否、参加中のメンバーには名前変更/ミュート/キック、
+						// 観戦クライアントにはキックだけを出す
+						if member.Spectator {
+							co.WithChild("kick", co.New(std.Button, func() {
+								co.WithData(std.ButtonData{
+									Text: "Kick",
+								})
+								co.WithCallbackData(std.ButtonCallbackData{
+									OnClick: func() { c.onKickClicked(member.ID) },
+								})
+							}))
+						} else if member.Approved {
+							if c.globalState.Settings.Teams > 0 {
+								co.WithChild("team", co.New(std.Button, func() {
+									co.WithData(std.ButtonData{
+										Text: TeamName(member.Team),
+									})
+									co.WithCallbackData(std.ButtonCallbackData{
+										OnClick: func() { c.onTeamClicked(member.ID) },
+									})
+								}))
+							}
+							co.WithChild("rename", co.New(std.Button, func() {
+								co.WithData(std.ButtonData{
+									Text: "Rename",
+								})
+								co.WithCallbackData(std.ButtonCallbackData{
+									OnClick: func() { c.onRenameClicked(member.ID) },
+								})
+							}))
+							co.WithChild("mute", co.New(std.Button, func() {
+								text := "Mute"
+								if member.Muted {
+									text = "Unmute"
+								}
+								co.WithData(std.ButtonData{
+									Text: text,
+								})
+								co.WithCallbackData(std.ButtonCallbackData{
+									OnClick: func() { c.onMuteClicked(member.ID) },
+								})
+							}))
+							co.WithChild("kick", co.New(std.Button, func() {
+								co.WithData(std.ButtonData{
+									Text: "Kick",
+								})
+								co.WithCallbackData(std.ButtonCallbackData{
+									OnClick: func() { c.onKickClicked(member.ID) },
+								})
+							}))
+						} else {
+							co.WithChild("approve", co.New(std.Button, func() {
+								co.WithData(std.ButtonData{
+									Text: "Approve",
+								})
+								co.WithCallbackData(std.ButtonCallbackData{
+									OnClick: func() { c.onApproveClicked(member.ID) },
+								})
+							}))
+							co.WithChild("deny", co.New(std.Button, func() {
+								co.WithData(std.ButtonData{
+									Text: "Deny",
+								})
+								co.WithCallbackData(std.ButtonCallbackData{
+									OnClick: func() { c.onDenyClicked(member.ID) },
+								})
+							}))
+						}
 					}))
 				}
 			}))
@@ -319,14 +440,9 @@ func (c *roomScreenComponent) Render() co.Instance {
 }
 
 func (c *roomScreenComponent) OnEvent(event mvc.Event) {
-	switch e := event.(type) {
-	case RoomMembersUpdatedEvent:
-		sort.StringSlice(e.Members).Sort()
-		if reflect.DeepEqual(c.members, e.Members) {
-			return
-		}
-		c.members = e.Members
-		c.Invalidate() // 再描画を要求
+	switch event.(type) {
+	case PlayerStateChangedEvent:
+		c.refreshMembers()
 	}
 }
 
@@ -351,6 +467,126 @@ func (c *roomScreenComponent) onPlayClicked() {
 	log.Println("play clicked from room")
 }
 
+// onCalibrationClicked はキャリブレーションパターンをプリセット順に切り替える。
+func (c *roomScreenComponent) onCalibrationClicked() {
+	settings := c.globalState.Settings
+	next := rules.CalibrationPresets[0]
+	for i, preset := range rules.CalibrationPresets {
+		if slices.Equal(preset, settings.CalibrationPattern) {
+			next = rules.CalibrationPresets[(i+1)%len(rules.CalibrationPresets)]
+			break
+		}
+	}
+	settings.CalibrationPattern = next
+	c.Invalidate()
+}
+
+func (c *roomScreenComponent) onSettingsClicked() {
+	c.app.SetActiveView(ViewNameSettings)
+}
+
+// onTeamsClicked はチーム数を 個人戦 → 2 → … → MaxTeams の順に切り替え、チームを分け直す。
+func (c *roomScreenComponent) onTeamsClicked() {
+	settings := c.globalState.Settings
+	switch {
+	case settings.Teams == 0:
+		settings.Teams = 2
+	case settings.Teams >= rules.MaxTeams:
+		settings.Teams = 0
+	default:
+		settings.Teams++
+	}
+	c.globalState.Session.Players.AssignTeams(settings.Teams)
+	c.refreshMembers()
+	c.Invalidate()
+}
+
+func (c *roomScreenComponent) onBalanceClicked() {
+	c.globalState.Session.Players.AssignTeams(c.globalState.Settings.Teams)
+	c.refreshMembers()
+}
+
+// onTeamClicked はメンバーを次のチームに移す。
+func (c *roomScreenComponent) onTeamClicked(id string) {
+	teams := c.globalState.Settings.Teams
+	c.globalState.Session.Players.Update(id, func(active *ActiveMember) {
+		active.Team = active.Team%teams + 1
+	})
+	c.refreshMembers()
+}
+
+func (c *roomScreenComponent) onApproveClicked(id string) {
+	if !c.globalState.Session.Players.Update(id, func(active *ActiveMember) {
+		active.Approved = true
+	}) {
+		return
+	}
+	log.Println("approved:", id)
+	c.refreshMembers()
+}
+
+func (c *roomScreenComponent) onDenyClicked(id string) {
+	c.remove(id, "denied by host")
+}
+
+func (c *roomScreenComponent) onKickClicked(id string) {
+	c.remove(id, "kicked by host")
+}
+
+// remove はメンバーを Registry から外して接続を切る。
+func (c *roomScreenComponent) remove(id, reason string) {
+	active, ok := c.globalState.Session.Players.Remove(id)
+	if !ok {
+		return
+	}
+	log.Println("removed:", id, active.Name(), reason)
+	if active.Link != nil {
+		deny(active.Peer, active.Link, reason)
+	}
+	c.refreshMembers()
+}
+
+func (c *roomScreenComponent) onMuteClicked(id string) {
+	c.globalState.Session.Players.Update(id, func(active *ActiveMember) {
+		active.Muted = !active.Muted
+	})
+	c.refreshMembers()
+}
+
+func (c *roomScreenComponent) onRenameClicked(id string) {
+	c.renaming = id
+	c.Invalidate()
+}
+
+func (c *roomScreenComponent) onRenameSubmitted(id, name string) {
+	c.renaming = ""
+	c.globalState.Session.Players.Update(id, func(active *ActiveMember) {
+		// 空にするとスコープが名乗った名前に戻す
+		active.Rename = strings.TrimSpace(name)
+	})
+	c.refreshMembers()
+	c.Invalidate()
+}
+
+func (c *roomScreenComponent) onRenameRejected() {
+	c.renaming = ""
+	c.Invalidate()
+}
+
+// onSpectateClicked は観戦ページを別のウィンドウで開く。
+// 2 台目のモニターやスマートフォンでは同じ URL を開けばよい。
+func (c *roomScreenComponent) onSpectateClicked() {
+	link := BaseURL() + "spectator/?dest=" + c.globalState.Session.ID()
+	if endpoint := SignalEndpoint(); endpoint != "" {
+		link += "&signal=" + url.QueryEscape(endpoint)
+	}
+	if pin := c.globalState.Settings.PIN; pin != "" {
+		link += "&pin=" + url.QueryEscape(pin)
+	}
+	log.Println("spectate:", link)
+	URLOpen(link)
+}
+
 func (c *roomScreenComponent) onBackClicked() {
 	c.app.SetActiveView(ViewNameHome)
 }
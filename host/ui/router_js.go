@@ -109,7 +109,7 @@ func updateHash(view ViewName) {
 	switch view {
 	default:
 		return
-	case ViewNameHome, ViewNamePlay, ViewNameLicenses, ViewNameRoom:
+	case ViewNameHome, ViewNamePlay, ViewNameLicenses, ViewNameRoom, ViewNameSettings:
 	}
 	targetHash := "#" + string(view)
 	if location.Get("hash").String() != targetHash {
@@ -5,6 +5,7 @@ package ui
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os/exec"
 	"runtime"
 )
@@ -31,11 +32,15 @@ func URLOpen(u string) {
 	cmd.Start()
 }
 
+// params はネイティブ版のホストのパラメータ。起動時にコマンドラインフラグから設定する。
+var params = url.Values{}
+
 func GetParam(key string) string {
-	return ""
+	return params.Get(key)
 }
 
 func SetParam(key, value string) {
+	params.Set(key, value)
 }
 
 func getViewFromHash() ViewName {
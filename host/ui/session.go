@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// inputPollInterval はフレームごとの更新がない画面でも入力を Registry に反映する間隔。
+const inputPollInterval = 100 * time.Millisecond
+
+// Session はアプリケーションの起動から終了まで、スコープからの接続を待ち受ける。
+// 画面が切り替わっても Listener とピアの接続はそのまま残り、
+// 各画面は Players を読むか PlayerStateChangedEvent を購読する。
+type Session struct {
+	Players *Registry
+	Input   *InputQueue
+
+	settings *Settings
+	host     Listener
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	lastStats time.Time // 最後に PeerConnection の統計を取った時刻
+}
+
+// NewSession は id で待ち受ける Session を作る。Start を呼ぶまで接続は受け付けない。
+func NewSession(id string, settings *Settings, notify func(PlayerStateChangedEvent)) *Session {
+	s := &Session{
+		Players:  NewRegistry(notify),
+		Input:    NewInputQueue(),
+		settings: settings,
+	}
+	s.host = newListener(id, s.onConnected)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+func (s *Session) ID() string {
+	return s.host.ID()
+}
+
+// Start はバックグラウンドで接続の待ち受けを始める。
+func (s *Session) Start() {
+	go func() {
+		log.Println("listen start:", s.host.ID())
+		defer log.Println("listen stop:", s.host.ID())
+		if err := s.host.Listen(s.ctx); err != nil {
+			log.Println("failed to listen", err)
+		}
+	}()
+}
+
+// Close は待ち受けをやめる。
+func (s *Session) Close() {
+	s.cancel()
+}
+
+// Pump は溜まった入力を Registry に反映する。UI スレッドから呼ぶこと。
+func (s *Session) Pump() {
+	for _, e := range s.Input.Drain() {
+		switch e.Kind {
+		case PeerConnected:
+			s.Players.Connect(e.Peer)
+		case PeerMessage:
+			s.Players.Apply(e, s.settings)
+		case PeerClosed:
+			s.Players.Disconnect(e.ID, e.Peer)
+		}
+	}
+	now := time.Now()
+	s.Players.Sweep(now)
+	if now.Sub(s.lastStats) >= statsSampleInterval {
+		s.lastStats = now
+		s.sampleStats()
+	}
+}
+
+// sampleStats は参加中のピアの PeerConnection から統計を取る。
+func (s *Session) sampleStats() {
+	for _, active := range s.Players.Members() {
+		if !active.State.Present() || active.Peer == nil {
+			continue
+		}
+		stats := transportStats(active.Peer.PeerConnection())
+		s.Players.Update(active.ID, func(a *ActiveMember) {
+			a.Stats.Transport = stats
+		})
+	}
+}
+
+// onConnected は接続してきたスコープのデータチャネルを受け付ける。
+// ピアのゴルーチンから呼ばれるので、Registry には Input を介して渡す。
+func (s *Session) onConnected(peer Peer) {
+	id := peer.ID()
+	input := s.Input
+	input.Push(PeerEvent{Kind: PeerConnected, ID: id, Peer: peer})
+	// Hello で宣言されるまではバイナリフレームを受け付けない。
+	// Hello と照準は別のチャネルのゴルーチンで届くので atomic にする
+	var binary atomic.Bool
+	peer.PeerConnection().OnDataChannel(func(dc *webrtc.DataChannel) {
+		log.Println("data channel opened:", id, dc.Label())
+		control := dc.Label() != schema.ChannelAim && dc.Label() != schema.ChannelShot
+		done := make(chan struct{})
+		dc.OnClose(func() {
+			log.Println("data channel closed:", id, dc.Label())
+			close(done)
+			if control {
+				input.Push(PeerEvent{Kind: PeerClosed, ID: id, Peer: peer})
+			}
+		})
+		// Hello はデフォルトチャネルで届くので、その Link が返信先になる
+		link := NewLink(dc)
+		if control {
+			dc.OnOpen(func() {
+				go s.ping(link, done)
+			})
+		}
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			if !msg.IsString {
+				if !binary.Load() {
+					log.Println("unexpected binary frame:", id)
+					return
+				}
+				var frame schema.Frame
+				if err := frame.UnmarshalBinary(msg.Data); err != nil {
+					log.Println("failed to decode frame:", id, err)
+					return
+				}
+				m := frame.Message()
+				input.Push(PeerEvent{
+					Kind: PeerMessage,
+					ID:   id,
+					Peer: peer,
+					Link: link,
+					Envelope: schema.Envelope{
+						Type:    m.MessageType(),
+						Version: schema.FrameVersion,
+						Seq:     frame.Seq,
+					},
+					Message:  m,
+					Received: time.Now(),
+				})
+				return
+			}
+			env, m, err := schema.Decode(msg.Data)
+			if err != nil {
+				log.Println("failed to decode message:", id, err)
+				return
+			}
+			if hello, ok := m.(schema.Hello); ok {
+				binary.Store(hello.Codec == schema.CodecBinary)
+			}
+			input.Push(PeerEvent{
+				Kind:     PeerMessage,
+				ID:       id,
+				Peer:     peer,
+				Link:     link,
+				Envelope: env,
+				Message:  m,
+				Received: time.Now(),
+			})
+		})
+	})
+}
+
+// ping はスコープとの時計のずれを測るため、接続中は定期的に Ping を送る。
+func (s *Session) ping(link *Link, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if err := link.Send(schema.Ping{Time: time.Now().UnixMilli()}); err != nil {
+			log.Println("failed to send ping:", err)
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/mokiat/gog/opt"
+	"github.com/mokiat/lacking/ui"
+	co "github.com/mokiat/lacking/ui/component"
+	"github.com/mokiat/lacking/ui/layout"
+	"github.com/mokiat/lacking/ui/std"
+
+	"github.com/nobonobo/gun-shooter/host/ui/widget"
+	"github.com/nobonobo/gun-shooter/rules"
+)
+
+var SettingsScreen = co.Define[*settingsScreenComponent]()
+
+type SettingsScreenData struct {
+	App *applicationComponent
+}
+
+// settingField は設定画面の 1 行。step は dir (+1 / -1) の向きに値を 1 段階変える。
+type settingField struct {
+	key   string
+	label string
+	value func(s *rules.MatchSettings) string
+	step  func(s *rules.MatchSettings, dir int)
+}
+
+// stepValue は v を step ずつ lo から hi の範囲で増減する。
+func stepValue[T ~int | ~int64 | ~float64](v *T, step, lo, hi T, dir int) {
+	*v = min(max(*v+T(dir)*step, lo), hi)
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.1f s", d.Seconds())
+}
+
+var settingFields = []settingField{
+	{
+		key:   "duration",
+		label: "Match length",
+		value: func(s *rules.MatchSettings) string { return formatSeconds(s.Duration) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.Duration, 15*time.Second, 15*time.Second, 5*time.Minute, dir)
+		},
+	},
+	{
+		key:   "countdown",
+		label: "Countdown",
+		value: func(s *rules.MatchSettings) string { return formatSeconds(s.Countdown) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.Countdown, time.Second, time.Second, 10*time.Second, dir)
+		},
+	},
+	{
+		key:   "spawn-start",
+		label: "Spawn interval (start)",
+		value: func(s *rules.MatchSettings) string { return formatSeconds(s.SpawnIntervalStart) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.SpawnIntervalStart, 100*time.Millisecond, 100*time.Millisecond, 3*time.Second, dir)
+		},
+	},
+	{
+		key:   "spawn-end",
+		label: "Spawn interval (end)",
+		value: func(s *rules.MatchSettings) string { return formatSeconds(s.SpawnIntervalEnd) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.SpawnIntervalEnd, 100*time.Millisecond, 100*time.Millisecond, 3*time.Second, dir)
+		},
+	},
+	{
+		key:   "lifetime-start",
+		label: "Target lifetime (start)",
+		value: func(s *rules.MatchSettings) string { return formatSeconds(s.LifetimeStart) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.LifetimeStart, 500*time.Millisecond, 500*time.Millisecond, 15*time.Second, dir)
+		},
+	},
+	{
+		key:   "lifetime-end",
+		label: "Target lifetime (end)",
+		value: func(s *rules.MatchSettings) string { return formatSeconds(s.LifetimeEnd) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.LifetimeEnd, 500*time.Millisecond, 500*time.Millisecond, 15*time.Second, dir)
+		},
+	},
+	{
+		key:   "target-radius",
+		label: "Target radius",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("%.0f px", s.TargetRadius) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.TargetRadius, 10, 40, 300, dir)
+			s.BullseyeRadius = min(s.BullseyeRadius, s.TargetRadius)
+		},
+	},
+	{
+		key:   "bullseye-radius",
+		label: "Bullseye radius",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("%.0f px", s.BullseyeRadius) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.BullseyeRadius, 10, 10, s.TargetRadius, dir)
+		},
+	},
+	{
+		key:   "speed-start",
+		label: "Target speed (start)",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("%.0f px/s", s.TargetSpeedStart) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.TargetSpeedStart, 20, 0, 600, dir)
+		},
+	},
+	{
+		key:   "speed-end",
+		label: "Target speed (end)",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("%.0f px/s", s.TargetSpeedEnd) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.TargetSpeedEnd, 20, 0, 600, dir)
+		},
+	},
+	{
+		key:   "special-rate",
+		label: "Special targets",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("%.0f %%", s.SpecialRate*100) },
+		step: func(s *rules.MatchSettings, dir int) {
+			// 小数を足し続けると誤差でプリセットと一致しなくなるので、整数のパーセントで増減する
+			percent := int(math.Round(s.SpecialRate * 100))
+			stepValue(&percent, 5, 0, 100, dir)
+			s.SpecialRate = float64(percent) / 100
+		},
+	},
+	{
+		key:   "hit-points",
+		label: "Hit points",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("+%d", s.HitPoints) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.HitPoints, 1, 1, 100, dir)
+		},
+	},
+	{
+		key:   "bullseye-points",
+		label: "Bullseye points",
+		value: func(s *rules.MatchSettings) string { return fmt.Sprintf("+%d", s.BullseyePoints) },
+		step: func(s *rules.MatchSettings, dir int) {
+			stepValue(&s.BullseyePoints, 1, 1, 100, dir)
+		},
+	},
+}
+
+type settingsScreenComponent struct {
+	co.BaseComponent
+
+	app *applicationComponent
+
+	titleFont *ui.Font
+	textFont  *ui.Font
+
+	globalState GlobalState
+}
+
+func (c *settingsScreenComponent) OnCreate() {
+	c.globalState = co.TypedValue[GlobalState](c.Scope())
+	componentData := co.GetData[SettingsScreenData](c.Properties())
+	c.app = componentData.App
+
+	c.titleFont = co.OpenFont(c.Scope(), "ui:///roboto-bold.ttf")
+	c.textFont = co.OpenFont(c.Scope(), "ui:///roboto-regular.ttf")
+}
+
+func (c *settingsScreenComponent) Render() co.Instance {
+	settings := &c.globalState.Settings.Match
+	return co.New(std.Container, func() {
+		co.WithData(std.ContainerData{
+			BackgroundColor: opt.V(ui.Black()),
+			Layout:          layout.Anchor(),
+		})
+
+		// Left Pane: Presets and Back button
+		co.WithChild("menu-pane", co.New(std.Container, func() {
+			co.WithLayoutData(layout.Data{
+				Top:    opt.V(0),
+				Bottom: opt.V(0),
+				Left:   opt.V(0),
+				Width:  opt.V(320),
+			})
+			co.WithData(std.ContainerData{
+				BackgroundColor: opt.V(ui.Black()),
+				Layout:          layout.Anchor(),
+			})
+
+			co.WithChild("holder", co.New(std.Element, func() {
+				co.WithLayoutData(layout.Data{
+					Left:           opt.V(75),
+					VerticalCenter: opt.V(0),
+				})
+				co.WithData(std.ElementData{
+					Layout: layout.Vertical(layout.VerticalSettings{
+						ContentAlignment: layout.HorizontalAlignmentLeft,
+						ContentSpacing:   15,
+					}),
+				})
+
+				for _, preset := range rules.MatchPresets {
+					co.WithChild("preset-"+preset.Name, co.New(widget.Button, func() {
+						co.WithData(widget.ButtonData{
+							Text: preset.Name,
+						})
+						co.WithCallbackData(widget.ButtonCallbackData{
+							OnClick: func() { c.onPresetClicked(preset) },
+						})
+					}))
+				}
+
+				co.WithChild("back-button", co.New(widget.Button, func() {
+					co.WithData(widget.ButtonData{
+						Text: "Back",
+					})
+					co.WithCallbackData(widget.ButtonCallbackData{
+						OnClick: c.onBackClicked,
+					})
+				}))
+			}))
+		}))
+
+		// Content Pane
+		co.WithChild("content-pane", co.New(std.Container, func() {
+			co.WithLayoutData(layout.Data{
+				Top:    opt.V(0),
+				Bottom: opt.V(0),
+				Left:   opt.V(320),
+				Right:  opt.V(0),
+			})
+			co.WithData(std.ContainerData{
+				BackgroundColor: opt.V(ui.RGB(0x11, 0x11, 0x11)),
+				Layout:          layout.Anchor(),
+			})
+
+			co.WithChild("fields", co.New(std.Element, func() {
+				co.WithLayoutData(layout.Data{
+					Top:              opt.V(20),
+					HorizontalCenter: opt.V(0),
+				})
+				co.WithData(std.ElementData{
+					Layout: layout.Vertical(layout.VerticalSettings{
+						ContentAlignment: layout.HorizontalAlignmentLeft,
+						ContentSpacing:   10,
+					}),
+				})
+
+				co.WithChild("title", co.New(std.Label, func() {
+					co.WithData(std.LabelData{
+						Font:      c.titleFont,
+						FontSize:  opt.V(float32(32)),
+						FontColor: opt.V(ui.White()),
+						Text:      "Match Settings: " + settings.PresetName(),
+					})
+				}))
+
+				for _, field := range settingFields {
+					co.WithChild("field-"+field.key, co.New(std.Element, func() {
+						co.WithData(std.ElementData{
+							Layout: layout.Horizontal(layout.HorizontalSettings{
+								ContentAlignment: layout.VerticalAlignmentCenter,
+								ContentSpacing:   10,
+							}),
+						})
+
+						co.WithChild("label", co.New(std.Label, func() {
+							co.WithLayoutData(layout.Data{
+								Width: opt.V(280),
+							})
+							co.WithData(std.LabelData{
+								Font:      c.textFont,
+								FontSize:  opt.V(float32(20)),
+								FontColor: opt.V(ui.RGB(0xAA, 0xAA, 0xAA)),
+								Text:      field.label,
+							})
+						}))
+						co.WithChild("decrease", co.New(std.Button, func() {
+							co.WithData(std.ButtonData{
+								Text: "-",
+							})
+							co.WithCallbackData(std.ButtonCallbackData{
+								OnClick: func() { c.onStepClicked(field, -1) },
+							})
+						}))
+						co.WithChild("value", co.New(std.Label, func() {
+							co.WithLayoutData(layout.Data{
+								Width: opt.V(100),
+							})
+							co.WithData(std.LabelData{
+								Font:      c.textFont,
+								FontSize:  opt.V(float32(20)),
+								FontColor: opt.V(ui.White()),
+								Text:      field.value(settings),
+							})
+						}))
+						co.WithChild("increase", co.New(std.Button, func() {
+							co.WithData(std.ButtonData{
+								Text: "+",
+							})
+							co.WithCallbackData(std.ButtonCallbackData{
+								OnClick: func() { c.onStepClicked(field, 1) },
+							})
+						}))
+					}))
+				}
+			}))
+		}))
+	})
+}
+
+func (c *settingsScreenComponent) onPresetClicked(preset rules.MatchPreset) {
+	c.globalState.Settings.Match = preset.Settings
+	c.save()
+}
+
+func (c *settingsScreenComponent) onStepClicked(field settingField, dir int) {
+	field.step(&c.globalState.Settings.Match, dir)
+	c.save()
+}
+
+// save は変更した設定をすぐに保存し、次の起動でも同じ設定で始められるようにする。
+func (c *settingsScreenComponent) save() {
+	if err := c.globalState.SettingsStore.Save(c.globalState.Settings.Match); err != nil {
+		log.Println("failed to save match settings:", err)
+	}
+	c.Invalidate()
+}
+
+func (c *settingsScreenComponent) onBackClicked() {
+	c.app.SetActiveView(ViewNameRoom)
+}
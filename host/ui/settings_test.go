@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/nobonobo/gun-shooter/rules"
+)
+
+// TestSettingFieldsRoundTrip は増やしてから同じ回数減らすとプリセットに戻ることを確かめる。
+func TestSettingFieldsRoundTrip(t *testing.T) {
+	for _, field := range settingFields {
+		for _, preset := range rules.MatchPresets {
+			s := preset.Settings
+			for range 3 {
+				field.step(&s, 1)
+			}
+			for range 3 {
+				field.step(&s, -1)
+			}
+			if s.PresetName() == preset.Name {
+				continue
+			}
+			// 上限に当たって戻らないのは誤差ではない
+			clamped := preset.Settings
+			field.step(&clamped, 1)
+			if field.value(&clamped) == field.value(&preset.Settings) {
+				continue
+			}
+			t.Errorf("%s from %s: %s, want %s", field.key, preset.Name, field.value(&s), field.value(&preset.Settings))
+		}
+	}
+}
+
+func TestSpecialRateStep(t *testing.T) {
+	field := settingFields[0]
+	for _, f := range settingFields {
+		if f.key == "special-rate" {
+			field = f
+		}
+	}
+	s := rules.DefaultMatchSettings
+	s.SpecialRate = 0.1
+	for range 4 {
+		field.step(&s, 1)
+	}
+	if s.SpecialRate != 0.3 {
+		t.Errorf("SpecialRate = %v, want exactly 0.3", s.SpecialRate)
+	}
+	for range 30 {
+		field.step(&s, 1)
+	}
+	if s.SpecialRate != 1 {
+		t.Errorf("SpecialRate = %v, want clamped to 1", s.SpecialRate)
+	}
+}
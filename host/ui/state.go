@@ -1,75 +1,124 @@
 package ui
 
 import (
-	"math"
+	"log"
 	"time"
 
 	"github.com/mokiat/lacking/audio"
 	"github.com/mokiat/lacking/game"
+	"github.com/nobonobo/gun-shooter/rules"
 	"github.com/nobonobo/gun-shooter/schema"
 )
 
+// ActiveMember は Registry が管理するスコープ 1 台分の情報。
 type ActiveMember struct {
-	Time        time.Time
-	Info        *schema.Info
-	Score       int
-	Calibration [4]schema.Point
-	Calibrated  int
+	ID    string
+	State PlayerState
+	// Time は最後にメッセージを受け取った時刻。
+	Time time.Time
+	Info schema.Info
+	// Ready はこの Match で準備できたと伝えてきたかどうか。Idle から戻るときに使う。
+	Ready bool
+	// Clock はスコープの時計とのずれの推定。
+	Clock *schema.ClockSync
+	// Link はスコープへの返信に使う。
+	Link *Link
+	// Peer はスコープとの接続。参加を断るときに切断する。
+	Peer Peer
+	// Token は再接続したスコープが同じプレイヤーであることを確かめるために Welcome で渡す。
+	Token string
+	// Spectator は観戦クライアント。Match には加わらず、入力も受け付けない。
+	Spectator bool
+	// Approved はホストが参加を承認したかどうか。未承認の間はプレイに加わらない。
+	Approved bool
+	// Rename が空でなければ、スコープが名乗った名前の代わりに表示する。
+	Rename string
+	// Muted の間はプレイ中の射撃を無視する。
+	Muted bool
+	// Team はチーム戦での所属チーム。0 ならチームに属さない。
+	Team int
+	// Stats は受信状況。診断パネルに表示する。
+	Stats NetStats
+	// Shots は次のフレームで処理する射撃。
+	Shots []Shot
+	// LastShot は受け付けた最後の ShotID。重複した射撃を捨てるのに使う。
+	LastShot uint32
 }
 
-type GlobalState struct {
-	AudioAPI    audio.API
-	Engine      *game.Engine
-	ResourceSet *game.ResourceSet
-	Actives     map[string]ActiveMember
-}
-
-// Calibrate はキャリブレーション4点を用いてバイリニア逆変換で座標を補正する。
-// am.Calibration[0..3] は TL, TR, BR, BL のターゲットを狙った際の生マーカー座標。
-// raw は補正対象の生座標。戻り値は補正後の正規化座標 (0-1)。
-func (am *ActiveMember) Calibrate() schema.Point {
-	raw := schema.Point{X: am.Info.X, Y: am.Info.Y}
-	// キャリブレーションターゲットの既知位置（正規化座標）
-	// TL(0.25,0.25), TR(0.75,0.25), BR(0.75,0.75), BL(0.25,0.75)
-	dst := [4]schema.Point{
-		{X: 0.25, Y: 0.25},
-		{X: 0.75, Y: 0.25},
-		{X: 0.75, Y: 0.75},
-		{X: 0.25, Y: 0.75},
+// Name は画面に表示する名前を返す。
+func (a ActiveMember) Name() string {
+	if a.Rename != "" {
+		return a.Rename
 	}
+	return a.Info.Name
+}
 
-	// Newton法でバイリニア逆変換: calib四角形内の (u,v) を求める
-	// bilinear(u,v) = (1-u)(1-v)*P0 + u(1-v)*P1 + uv*P2 + (1-u)v*P3
-	u, v := 0.5, 0.5
-	for i := 0; i < 20; i++ {
-		// 残差
-		fx := (1-u)*(1-v)*am.Calibration[0].X + u*(1-v)*am.Calibration[1].X + u*v*am.Calibration[2].X + (1-u)*v*am.Calibration[3].X - raw.X
-		fy := (1-u)*(1-v)*am.Calibration[0].Y + u*(1-v)*am.Calibration[1].Y + u*v*am.Calibration[2].Y + (1-u)*v*am.Calibration[3].Y - raw.Y
-
-		if math.Abs(fx) < 1e-10 && math.Abs(fy) < 1e-10 {
+// apply は照準や射撃など、状態を変えないメッセージを反映する。
+func (a *ActiveMember) apply(msg schema.Message) {
+	switch m := msg.(type) {
+	case schema.Aim:
+		a.Info.X = m.X
+		a.Info.Y = m.Y
+	case schema.Fire:
+		if !a.Approved {
 			break
 		}
-
-		// ヤコビアン
-		dxdu := -(1-v)*am.Calibration[0].X + (1-v)*am.Calibration[1].X + v*am.Calibration[2].X - v*am.Calibration[3].X
-		dxdv := -(1-u)*am.Calibration[0].X - u*am.Calibration[1].X + u*am.Calibration[2].X + (1-u)*am.Calibration[3].X
-		dydu := -(1-v)*am.Calibration[0].Y + (1-v)*am.Calibration[1].Y + v*am.Calibration[2].Y - v*am.Calibration[3].Y
-		dydv := -(1-u)*am.Calibration[0].Y - u*am.Calibration[1].Y + u*am.Calibration[2].Y + (1-u)*am.Calibration[3].Y
-
-		det := dxdu*dydv - dxdv*dydu
-		if math.Abs(det) < 1e-12 {
+		// ShotID は順序付きチャネルで単調増加するので、最後に受けた ID 以下は重複
+		if m.ShotID <= a.LastShot {
+			log.Println("duplicate shot:", a.ID, m.ShotID)
 			break
 		}
+		a.LastShot = m.ShotID
+		shot := Shot{ID: m.ShotID, Aim: schema.Point{X: m.X, Y: m.Y}}
+		if m.Time != 0 {
+			shot.Time = a.Clock.Local(time.UnixMilli(m.Time))
+		}
+		a.Shots = append(a.Shots, shot)
+	case schema.Pong:
+		a.Clock.Add(time.UnixMilli(m.Ping), time.UnixMilli(m.Time), time.Now())
+	}
+}
 
-		du := -(fx*dydv - fy*dxdv) / det
-		dv := -(dxdu*fy - dydu*fx) / det
-		u += du
-		v += dv
+// presentState は Idle から戻るときの状態。
+func (a ActiveMember) presentState() PlayerState {
+	if a.Ready {
+		return PlayerReady
 	}
+	return PlayerJoined
+}
 
-	// (u,v) → ターゲット空間にマッピング
-	rx := (1-u)*(1-v)*dst[0].X + u*(1-v)*dst[1].X + u*v*dst[2].X + (1-u)*v*dst[3].X
-	ry := (1-u)*(1-v)*dst[0].Y + u*(1-v)*dst[1].Y + u*v*dst[2].Y + (1-u)*v*dst[3].Y
+// Shot はホストの時計に換算済みの射撃。
+type Shot struct {
+	ID   uint32
+	Aim  schema.Point
+	Time time.Time
+}
+
+type GlobalState struct {
+	AudioAPI    audio.API
+	Engine      *game.Engine
+	ResourceSet *game.ResourceSet
+	Session     *Session
+	Settings    *Settings
+
+	Calibrations  CalibrationStore
+	SettingsStore SettingsStore
+}
 
-	return schema.Point{X: rx, Y: ry}
+// Settings は画面をまたいで共有するホストの設定。
+type Settings struct {
+	CalibrationPattern rules.CalibrationPattern
+	// MaxRewind は遅れて届いた射撃を判定するために巻き戻す上限。
+	MaxRewind time.Duration
+	// PIN が空でなければ、Hello で同じ PIN を送ったスコープだけを受け付ける。
+	PIN string
+	// RequireApproval が true なら新しいスコープはルーム画面で承認されるまで参加できない。
+	// 自動テストなどでは URL パラメーター approve=auto で無効にできる。
+	RequireApproval bool
+	// Teams はチーム戦のチーム数。0 なら個人戦。
+	Teams int
+	// Match は次に始める Match の長さと難易度。設定画面で変えると SettingsStore に保存する。
+	Match rules.MatchSettings
+	// Debug が true ならすべてのスコープに SimState を送る。cmd/scopesim で試すときに使う。
+	Debug bool
 }
@@ -0,0 +1,35 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/rules"
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// CalibrationRecord は1台のスコープについて保存するキャリブレーション結果。
+type CalibrationRecord struct {
+	Pattern []schema.Point `json:"pattern"`
+	Samples []schema.Point `json:"samples"`
+	Quality float64        `json:"quality"`
+	SavedAt time.Time      `json:"savedAt"`
+}
+
+// CalibrationStore はスコープの端末IDと画面解像度をキーにキャリブレーションを保存する。
+// 実装はネイティブではJSONファイル、ブラウザでは localStorage。
+type CalibrationStore interface {
+	Load(key string) (CalibrationRecord, bool)
+	Save(key string, record CalibrationRecord) error
+}
+
+func calibrationKey(device string, width, height int) string {
+	return fmt.Sprintf("%s@%dx%d", device, width, height)
+}
+
+// SettingsStore は最後に使った MatchSettings を次の起動まで残す。
+// 実装はネイティブではJSONファイル、ブラウザでは localStorage。
+type SettingsStore interface {
+	Load() (rules.MatchSettings, bool)
+	Save(settings rules.MatchSettings) error
+}
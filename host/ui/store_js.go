@@ -0,0 +1,80 @@
+//go:build js
+
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"syscall/js"
+
+	"github.com/nobonobo/gun-shooter/rules"
+)
+
+const calibrationStorePrefix = "gun-shooter.calibration."
+
+func NewCalibrationStore() CalibrationStore {
+	return &localCalibrationStore{
+		storage: js.Global().Get("localStorage"),
+	}
+}
+
+type localCalibrationStore struct {
+	storage js.Value
+}
+
+func (s *localCalibrationStore) Load(key string) (CalibrationRecord, bool) {
+	v := s.storage.Call("getItem", calibrationStorePrefix+key)
+	if v.IsNull() {
+		return CalibrationRecord{}, false
+	}
+	var record CalibrationRecord
+	if err := json.Unmarshal([]byte(v.String()), &record); err != nil {
+		log.Println("failed to unmarshal calibration:", key, err)
+		return CalibrationRecord{}, false
+	}
+	return record, true
+}
+
+func (s *localCalibrationStore) Save(key string, record CalibrationRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal calibration: %w", err)
+	}
+	s.storage.Call("setItem", calibrationStorePrefix+key, string(b))
+	return nil
+}
+
+const settingsStoreKey = "gun-shooter.match-settings"
+
+func NewSettingsStore() SettingsStore {
+	return &localSettingsStore{
+		storage: js.Global().Get("localStorage"),
+	}
+}
+
+type localSettingsStore struct {
+	storage js.Value
+}
+
+func (s *localSettingsStore) Load() (rules.MatchSettings, bool) {
+	v := s.storage.Call("getItem", settingsStoreKey)
+	if v.IsNull() {
+		return rules.MatchSettings{}, false
+	}
+	var settings rules.MatchSettings
+	if err := json.Unmarshal([]byte(v.String()), &settings); err != nil {
+		log.Println("failed to unmarshal match settings:", err)
+		return rules.MatchSettings{}, false
+	}
+	return settings, true
+}
+
+func (s *localSettingsStore) Save(settings rules.MatchSettings) error {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal match settings: %w", err)
+	}
+	s.storage.Call("setItem", settingsStoreKey, string(b))
+	return nil
+}
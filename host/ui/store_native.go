@@ -0,0 +1,110 @@
+//go:build !js
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nobonobo/gun-shooter/rules"
+)
+
+func NewCalibrationStore() CalibrationStore {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	s := &fileCalibrationStore{
+		path:    filepath.Join(dir, "gun-shooter", "calibration.json"),
+		records: make(map[string]CalibrationRecord),
+	}
+	if err := s.read(); err != nil {
+		log.Println("failed to read calibration store:", err)
+	}
+	return s
+}
+
+type fileCalibrationStore struct {
+	path    string
+	records map[string]CalibrationRecord
+}
+
+func (s *fileCalibrationStore) Load(key string) (CalibrationRecord, bool) {
+	record, ok := s.records[key]
+	return record, ok
+}
+
+func (s *fileCalibrationStore) Save(key string, record CalibrationRecord) error {
+	s.records[key] = record
+	b, err := json.MarshalIndent(s.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal calibration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create calibration dir: %w", err)
+	}
+	if err := os.WriteFile(s.path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write calibration: %w", err)
+	}
+	return nil
+}
+
+func (s *fileCalibrationStore) read() error {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &s.records)
+}
+
+func NewSettingsStore() SettingsStore {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return &fileSettingsStore{
+		path: filepath.Join(dir, "gun-shooter", "match-settings.json"),
+	}
+}
+
+type fileSettingsStore struct {
+	path string
+}
+
+func (s *fileSettingsStore) Load() (rules.MatchSettings, bool) {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println("failed to read match settings:", err)
+		}
+		return rules.MatchSettings{}, false
+	}
+	var settings rules.MatchSettings
+	if err := json.Unmarshal(b, &settings); err != nil {
+		log.Println("failed to unmarshal match settings:", err)
+		return rules.MatchSettings{}, false
+	}
+	return settings, true
+}
+
+func (s *fileSettingsStore) Save(settings rules.MatchSettings) error {
+	b, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal match settings: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create settings dir: %w", err)
+	}
+	if err := os.WriteFile(s.path, b, 0o644); err != nil {
+		return fmt.Errorf("failed to write match settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/mokiat/lacking/ui"
+
+	"github.com/nobonobo/gun-shooter/rules"
+)
+
+// teamColors[i] はチーム i+1 の色。照準の点とスコアのポップアップに使う。
+var teamColors = [rules.MaxTeams]ui.Color{
+	ui.RGB(0xE7, 0x4C, 0x3C),
+	ui.RGB(0x34, 0x98, 0xDB),
+	ui.RGB(0x2E, 0xCC, 0x71),
+	ui.RGB(0xF1, 0xC4, 0x0F),
+}
+
+var teamNames = [rules.MaxTeams]string{"Red", "Blue", "Green", "Yellow"}
+
+// TeamColor はチームの色を返す。チームに属さなければ ok は false。
+func TeamColor(team int) (ui.Color, bool) {
+	if team < 1 || team > rules.MaxTeams {
+		return ui.Color{}, false
+	}
+	return teamColors[team-1], true
+}
+
+// TeamName は画面に表示するチームの名前を返す。
+func TeamName(team int) string {
+	if team < 1 || team > rules.MaxTeams {
+		return "-"
+	}
+	return teamNames[team-1]
+}
+
+// teamLabel は HUD と結果一覧に表示するチーム名と合計スコア。
+func teamLabel(s rules.TeamScore) string {
+	return fmt.Sprintf("%s: %d", TeamName(s.Team), s.Score)
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"context"
+
+	"github.com/nobonobo/rtcconnect/node"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/nobonobo/gun-shooter/signaling"
+)
+
+// Peer は接続してきたスコープ。
+type Peer interface {
+	ID() string
+	PeerConnection() *webrtc.PeerConnection
+}
+
+// Listener はスコープからの接続を待ち受ける。
+type Listener interface {
+	ID() string
+	Listen(ctx context.Context) error
+}
+
+// SignalEndpoint は signal パラメータで指定されたシグナリングサーバーの URL。
+// 空なら rtcconnect の公開サービスを使う。
+func SignalEndpoint() string {
+	return GetParam("signal")
+}
+
+// newListener は id で待ち受け、スコープが接続するたびに onConnected を呼ぶ Listener を作る。
+func newListener(id string, onConnected func(Peer)) Listener {
+	if endpoint := SignalEndpoint(); endpoint != "" {
+		host := signaling.NewHost(endpoint, id)
+		host.OnConnected = func(peer *signaling.Node) { onConnected(peer) }
+		return host
+	}
+	host := node.NewHost(id)
+	host.OnConnected = func(peer *node.Node) { onConnected(peer) }
+	return host
+}
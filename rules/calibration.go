@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// MinCalibrationQuality を下回るキャリブレーションは退化しているとみなして棄却する。
+const MinCalibrationQuality = 0.05
+
+// CalibrationPattern はキャリブレーションで撃つターゲットの位置（正規化座標）の並び。
+// 4点以上であれば任意の配置を使え、5点以上では最小二乗で写像を求める。
+type CalibrationPattern []schema.Point
+
+var (
+	// Pattern4 は中央寄りの4点 TL, TR, BR, BL。
+	Pattern4 = CalibrationPattern{
+		{X: 0.25, Y: 0.25},
+		{X: 0.75, Y: 0.25},
+		{X: 0.75, Y: 0.75},
+		{X: 0.25, Y: 0.75},
+	}
+	// Pattern5 は隅に近い4点と中央。
+	Pattern5 = CalibrationPattern{
+		{X: 0.1, Y: 0.1},
+		{X: 0.9, Y: 0.1},
+		{X: 0.5, Y: 0.5},
+		{X: 0.9, Y: 0.9},
+		{X: 0.1, Y: 0.9},
+	}
+	// Pattern9 は隅に近い点を含む 3x3 の格子。
+	Pattern9 = CalibrationPattern{
+		{X: 0.1, Y: 0.1},
+		{X: 0.5, Y: 0.1},
+		{X: 0.9, Y: 0.1},
+		{X: 0.9, Y: 0.5},
+		{X: 0.5, Y: 0.5},
+		{X: 0.1, Y: 0.5},
+		{X: 0.1, Y: 0.9},
+		{X: 0.5, Y: 0.9},
+		{X: 0.9, Y: 0.9},
+	}
+)
+
+// CalibrationPresets は UI で切り替えられる既定のパターン。
+var CalibrationPresets = []CalibrationPattern{Pattern4, Pattern5, Pattern9}
+
+// ParseCalibrationPattern はプリセット名 ("4", "5", "9") または
+// "x,y x,y ..." 形式の正規化座標の並びからパターンを作る。
+func ParseCalibrationPattern(s string) (CalibrationPattern, error) {
+	switch strings.TrimSpace(s) {
+	case "", "4":
+		return Pattern4, nil
+	case "5":
+		return Pattern5, nil
+	case "9":
+		return Pattern9, nil
+	}
+	var pattern CalibrationPattern
+	for _, field := range strings.Fields(strings.ReplaceAll(s, ";", " ")) {
+		xs, ys, ok := strings.Cut(field, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid calibration point %q", field)
+		}
+		x, err := strconv.ParseFloat(xs, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calibration point %q: %w", field, err)
+		}
+		y, err := strconv.ParseFloat(ys, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calibration point %q: %w", field, err)
+		}
+		if x < 0 || x > 1 || y < 0 || y > 1 {
+			return nil, fmt.Errorf("calibration point %q out of range", field)
+		}
+		pattern = append(pattern, schema.Point{X: x, Y: y})
+	}
+	if len(pattern) < 4 {
+		return nil, fmt.Errorf("calibration pattern needs at least 4 points, got %d", len(pattern))
+	}
+	return pattern, nil
+}
@@ -0,0 +1,30 @@
+package rules
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock はルールエンジンが参照する現在時刻の供給源。
+// テストでは任意の時刻を返す実装に差し替える。
+type Clock interface {
+	Now() time.Time
+}
+
+// Rand はターゲット配置に使う乱数源。*rand.Rand はこれを満たす。
+type Rand interface {
+	Float64() float64
+}
+
+// SystemClock は time.Now を返す Clock。
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+type globalRand struct{}
+
+func (globalRand) Float64() float64 {
+	return rand.Float64()
+}
@@ -0,0 +1,78 @@
+package rules
+
+import (
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// Event は Match が OnEvent に通知するイベント。
+type Event any
+
+// ModeChanged はモードが遷移したときに通知される。
+type ModeChanged struct {
+	From Mode
+	To   Mode
+}
+
+// TargetSpawned は新しいターゲットが出現したときに通知される。
+type TargetSpawned struct {
+	Target Target
+}
+
+// TargetExpired はターゲットが撃たれずに寿命を迎えたときに通知される。
+type TargetExpired struct {
+	Target Target
+}
+
+// CalibrationShot はキャリブレーション中の射撃で通知される。
+// Accepted が false の場合は既に現在のポイントを撃ち終えていた射撃。
+type CalibrationShot struct {
+	PlayerID string
+	Index    int
+	Accepted bool
+}
+
+// CalibrationCompleted はプレイヤーのキャリブレーションが確定したときに通知される。
+type CalibrationCompleted struct {
+	PlayerID string
+	Quality  float64
+}
+
+// CalibrationRejected は撃った点の配置が退化していてキャリブレーションを
+// 棄却したときに通知される。プレイヤーは最初のポイントから撃ち直す。
+type CalibrationRejected struct {
+	PlayerID string
+	Quality  float64
+	Err      error
+}
+
+// Hit はターゲットに命中したときに通知される。Position はスクリーン座標。
+// TargetPenalty では Points が負になる。
+type Hit struct {
+	PlayerID string
+	Target   Target
+	Position schema.Point
+	Points   int
+	Bullseye bool
+	// Combo は Points に掛けたコンボの倍率。
+	Combo int
+	// Destroyed はこの命中でターゲットが壊れたかどうか。
+	// TargetArmored は残りの命中数があるうちは false で、得点も入らない。
+	Destroyed bool
+	// Extension は TargetTime を壊して延びた制限時間。
+	Extension time.Duration
+	// Rewind は射撃時刻まで巻き戻して判定した時間。
+	Rewind time.Duration
+}
+
+// Miss はキャリブレーション以外の射撃がどのターゲットにも当たらなかったときに通知される。
+type Miss struct {
+	PlayerID string
+	Position schema.Point
+}
+
+// GameOver は制限時間が終了したときに通知される。Players は ID 順の最終結果。
+type GameOver struct {
+	Players []Player
+}
@@ -0,0 +1,130 @@
+package rules
+
+import (
+	"math"
+	"time"
+)
+
+// TargetKind はターゲットの種類。種類ごとに大きさ、動き、得点のルールが違う。
+type TargetKind int
+
+const (
+	// TargetNormal は設定どおりの大きさと得点のターゲット。
+	TargetNormal TargetKind = iota
+	// TargetBonus は小さく速いが、得点が bonusMultiplier 倍になる。
+	TargetBonus
+	// TargetPenalty は撃ってはいけない人質。当てると中心に当てたときの得点を失う。
+	TargetPenalty
+	// TargetArmored は armoredHits 回当てないと壊れない。壊したときに回数分の得点が入る。
+	TargetArmored
+	// TargetTime は当てると制限時間が TimeExtension 延びる。
+	TargetTime
+	// TargetShrinking は寿命が尽きるまでに shrinkRatio まで小さくなり、小さいほど得点が高い。
+	TargetShrinking
+)
+
+// targetKinds は特殊なターゲットとして選ばれる種類。
+var targetKinds = []TargetKind{TargetBonus, TargetPenalty, TargetArmored, TargetTime, TargetShrinking}
+
+func (k TargetKind) String() string {
+	switch k {
+	case TargetNormal:
+		return "normal"
+	case TargetBonus:
+		return "bonus"
+	case TargetPenalty:
+		return "penalty"
+	case TargetArmored:
+		return "armored"
+	case TargetTime:
+		return "time"
+	case TargetShrinking:
+		return "shrinking"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	bonusScale      = 0.5
+	bonusMultiplier = 3
+	// bonusMinSpeed は速さの設定が 0 でもボーナスのターゲットだけは動かすための下限。
+	bonusMinSpeed   = 150
+	bonusSpeedScale = 2
+	armoredHits     = 3
+	shrinkRatio     = 0.3
+)
+
+// TimeExtension は TargetTime を壊したときに延びる制限時間。
+const TimeExtension = 5 * time.Second
+
+// scale はスポーンしたときの半径の TargetRadius に対する倍率。
+func (k TargetKind) scale() float64 {
+	if k == TargetBonus {
+		return bonusScale
+	}
+	return 1
+}
+
+// speed は設定の速さ speed からこの種類の速さを決める。
+func (k TargetKind) speed(speed float64) float64 {
+	if k == TargetBonus {
+		return math.Max(speed, bonusMinSpeed) * bonusSpeedScale
+	}
+	return speed
+}
+
+// hits は壊すのに必要な命中数。
+func (k TargetKind) hits() int {
+	if k == TargetArmored {
+		return armoredHits
+	}
+	return 1
+}
+
+// newKind は rate の割合で特殊なターゲットを選ぶ。
+func newKind(rnd Rand, rate float64) TargetKind {
+	if rnd.Float64() >= rate {
+		return TargetNormal
+	}
+	return targetKinds[int(rnd.Float64()*float64(len(targetKinds)))%len(targetKinds)]
+}
+
+// RadiusAt は時刻 at の半径を返す。TargetShrinking 以外は変わらない。
+func (t Target) RadiusAt(at time.Time) float64 {
+	if t.Kind != TargetShrinking || t.Lifetime <= 0 {
+		return t.Size
+	}
+	progress := min(max(at.Sub(t.SpawnTime).Seconds()/t.Lifetime.Seconds(), 0), 1)
+	return t.Size * (1 - (1-shrinkRatio)*progress)
+}
+
+// BullseyeRadius は時刻 at のこのターゲットの中心の半径を返す。
+// 設定の TargetRadius に対する BullseyeRadius の比をターゲットの大きさに合わせる。
+func (m *Match) BullseyeRadius(t Target, at time.Time) float64 {
+	return t.RadiusAt(at) * m.settings.BullseyeRadius / m.settings.TargetRadius
+}
+
+// score は時刻 at に中心から dist の位置に当たったときの得点と、中心に当たったかを返す。
+// destroyed が false (TargetArmored がまだ壊れていない) なら得点は入らない。
+func (m *Match) score(t Target, dist float64, at time.Time, destroyed bool) (int, bool) {
+	bullseye := dist <= m.BullseyeRadius(t, at)
+	points := m.settings.HitPoints
+	if bullseye {
+		points = m.settings.BullseyePoints
+	}
+	switch t.Kind {
+	case TargetBonus:
+		points *= bonusMultiplier
+	case TargetPenalty:
+		points = -m.settings.BullseyePoints
+	case TargetArmored:
+		if !destroyed {
+			return 0, bullseye
+		}
+		points *= armoredHits
+	case TargetShrinking:
+		points = int(math.Round(float64(points) * t.Size / t.RadiusAt(at)))
+	}
+	return points, bullseye
+}
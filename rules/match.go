@@ -0,0 +1,599 @@
+// Package rules は描画から独立したガンシューティングのゲームルールを提供する。
+//
+// Match は時刻と乱数を外部から注入して動作するため、GPU やウィンドウなしで
+// スコアや難易度曲線を検証でき、ホスト画面以外のフロントエンドからも再利用できる。
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+const (
+	MarkerSize = 200
+	// DefaultMaxRewind は射撃時刻まで巻き戻して命中判定する上限のデフォルト値。
+	DefaultMaxRewind = 200 * time.Millisecond
+)
+
+type Mode int
+
+const (
+	ModeWaiting Mode = iota
+	ModeCalibration
+	ModeCountdown
+	ModePlaying
+	ModeGameOver
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeWaiting:
+		return "waiting"
+	case ModeCalibration:
+		return "calibration"
+	case ModeCountdown:
+		return "countdown"
+	case ModePlaying:
+		return "playing"
+	case ModeGameOver:
+		return "gameover"
+	default:
+		return "unknown"
+	}
+}
+
+type MatchInfo struct {
+	// Clock が nil の場合は SystemClock を使う。
+	Clock Clock
+	// Rand が nil の場合は math/rand のグローバル乱数を使う。
+	Rand Rand
+	// Width, Height はターゲットを配置するスクリーンのピクセルサイズ。
+	Width, Height float64
+	// CalibrationPattern が nil の場合は Pattern4 を使う。
+	CalibrationPattern CalibrationPattern
+	// MaxRewind は遅れて届いた射撃のためにターゲットの状態を巻き戻す上限。
+	// 0 の場合は DefaultMaxRewind を使い、負の場合は巻き戻さない。
+	MaxRewind time.Duration
+	// Settings は Normalize してから使う。ゼロ値なら DefaultMatchSettings になる。
+	Settings MatchSettings
+}
+
+// Match は1回分のゲームの進行を管理する。
+// スレッドセーフではないため、呼び出しは単一のゴルーチンから行うこと。
+type Match struct {
+	// OnEvent はルール上の出来事が起きるたびに同期的に呼ばれる。
+	OnEvent func(Event)
+
+	clock    Clock
+	rand     Rand
+	settings MatchSettings
+
+	width, height float64
+
+	mode       Mode
+	deadline   time.Time
+	startTime  time.Time
+	pattern    CalibrationPattern
+	calibIndex int
+
+	players map[string]*Player
+
+	maxRewind     time.Duration
+	targets       []Target
+	expired       []Target // 巻き戻し用に MaxRewind の間だけ残す期限切れのターゲット
+	nextTargetID  int
+	nextSpawnTime time.Time
+}
+
+func NewMatch(info MatchInfo) *Match {
+	m := &Match{
+		OnEvent:   func(Event) {},
+		clock:     info.Clock,
+		rand:      info.Rand,
+		settings:  info.Settings.Normalize(),
+		width:     info.Width,
+		height:    info.Height,
+		mode:      ModeWaiting,
+		pattern:   info.CalibrationPattern,
+		players:   make(map[string]*Player),
+		maxRewind: info.MaxRewind,
+	}
+	if m.maxRewind == 0 {
+		m.maxRewind = DefaultMaxRewind
+	}
+	if m.maxRewind < 0 {
+		m.maxRewind = 0
+	}
+	if m.clock == nil {
+		m.clock = SystemClock{}
+	}
+	if m.rand == nil {
+		m.rand = globalRand{}
+	}
+	if len(m.pattern) < 4 {
+		m.pattern = Pattern4
+	}
+	return m
+}
+
+func (m *Match) Mode() Mode {
+	return m.mode
+}
+
+// Settings はこの Match の長さと難易度。
+func (m *Match) Settings() MatchSettings {
+	return m.settings
+}
+
+// CalibrationPattern はこの Match で使うキャリブレーションターゲットの並び。
+func (m *Match) CalibrationPattern() CalibrationPattern {
+	return m.pattern
+}
+
+// CalibrationIndex は全員がまだ撃ち終えていないキャリブレーションポイントの番号。
+func (m *Match) CalibrationIndex() int {
+	return m.calibIndex
+}
+
+// Remaining はカウントダウンまたはプレイ中の残り時間を返す。
+func (m *Match) Remaining() time.Duration {
+	if m.mode != ModeCountdown && m.mode != ModePlaying {
+		return 0
+	}
+	return max(m.deadline.Sub(m.clock.Now()), 0)
+}
+
+func (m *Match) Targets() []Target {
+	return m.targets
+}
+
+func (m *Match) Player(id string) (*Player, bool) {
+	p, ok := m.players[id]
+	return p, ok
+}
+
+// Players は参加したことのある全プレイヤーを ID 順に返す。
+func (m *Match) Players() []*Player {
+	result := make([]*Player, 0, len(m.players))
+	for _, id := range slices.Sorted(maps.Keys(m.players)) {
+		result = append(result, m.players[id])
+	}
+	return result
+}
+
+// NormalizedPosition は ScreenPosition の逆変換。
+func (m *Match) NormalizedPosition(p schema.Point) schema.Point {
+	return schema.Point{
+		X: (p.X - MarkerSize/2) / (m.width - MarkerSize),
+		Y: (p.Y - MarkerSize/2) / (m.height - MarkerSize),
+	}
+}
+
+// Rank はスコアによる順位を返す。同点は同順位で、未参加なら 0。
+func (m *Match) Rank(id string) int {
+	p, ok := m.players[id]
+	if !ok {
+		return 0
+	}
+	rank := 1
+	for _, other := range m.players {
+		if other.Score > p.Score {
+			rank++
+		}
+	}
+	return rank
+}
+
+// SetScreenSize はターゲット配置と命中判定に使うスクリーンサイズを更新する。
+func (m *Match) SetScreenSize(width, height float64) {
+	m.width = width
+	m.height = height
+}
+
+// ScreenPosition は正規化座標 (0-1) をスクリーンのピクセル座標に変換する。
+func (m *Match) ScreenPosition(p schema.Point) schema.Point {
+	return schema.Point{
+		X: p.X*(m.width-MarkerSize) + MarkerSize/2,
+		Y: p.Y*(m.height-MarkerSize) + MarkerSize/2,
+	}
+}
+
+// Input はスコープから届いた最新の照準を反映する。
+// 射撃は Fire で別に渡す。
+func (m *Match) Input(id string, info schema.Info) {
+	p, ok := m.players[id]
+	if !ok {
+		p = &Player{ID: id}
+		p.resetCalibration(m.pattern)
+		m.players[id] = p
+	}
+	p.Name = info.Name
+	p.Device = info.Device
+	p.Active = true
+	p.Fire = false
+	p.Aim = schema.Point{X: info.X, Y: info.Y}
+}
+
+// Fire は撃った瞬間の生マーカー座標 aim で射撃を 1 回処理する。
+// at はホストの時計に換算した射撃時刻で、命中判定は MaxRewind の範囲で
+// その時点のターゲットに対して行う。ゼロ値なら現在時刻とみなす。
+// Input されていないプレイヤーの射撃は無視する。
+func (m *Match) Fire(id string, aim schema.Point, at time.Time) {
+	p, ok := m.players[id]
+	if !ok {
+		return
+	}
+	if m.mode == ModeWaiting || (p.Muted && m.mode != ModeCalibration) {
+		return
+	}
+	p.Fire = true
+	p.Aim = aim
+	m.fire(p, m.rewindTime(at))
+}
+
+// Mute はプレイヤーの射撃を無視するかどうかを切り替える。
+// キャリブレーションを止めないよう、キャリブレーション中の射撃は常に受け付ける。
+func (m *Match) Mute(id string, muted bool) {
+	if p, ok := m.players[id]; ok {
+		p.Muted = muted
+	}
+}
+
+// rewindTime は射撃時刻 at を現在時刻から MaxRewind までの範囲に収める。
+func (m *Match) rewindTime(at time.Time) time.Time {
+	now := m.clock.Now()
+	if at.IsZero() || at.After(now) {
+		return now
+	}
+	if oldest := now.Add(-m.maxRewind); at.Before(oldest) {
+		return oldest
+	}
+	return at
+}
+
+// Leave はプレイヤーを非アクティブにする。スコアは結果表示のために残る。
+func (m *Match) Leave(id string) {
+	if p, ok := m.players[id]; ok {
+		p.Active = false
+	}
+}
+
+// RestoreCalibration は保存済みのキャリブレーション結果をプレイヤーに適用する。
+// samples は現在のパターンと同じ並びの生マーカー座標でなければならない。
+func (m *Match) RestoreCalibration(id string, samples []schema.Point) error {
+	p, ok := m.players[id]
+	if !ok {
+		return fmt.Errorf("unknown player %q", id)
+	}
+	if len(samples) != len(m.pattern) {
+		return fmt.Errorf("calibration has %d points, pattern needs %d", len(samples), len(m.pattern))
+	}
+	p.Calibration = slices.Clone(samples)
+	p.Calibrated = len(samples)
+	if err := p.solveCalibration(m.pattern); err != nil {
+		p.resetCalibration(m.pattern)
+		return err
+	}
+	return nil
+}
+
+// Start は参加者の準備を待っている Match をキャリブレーションに進める。
+// 既に始まっている場合は何もしない。
+func (m *Match) Start() {
+	if m.mode == ModeWaiting {
+		m.setMode(ModeCalibration)
+	}
+}
+
+// Recalibrate は全員のキャリブレーションを破棄してキャリブレーションからやり直す。
+func (m *Match) Recalibrate() {
+	for _, p := range m.players {
+		p.resetCalibration(m.pattern)
+	}
+	m.calibIndex = 0
+	m.clearTargets()
+	m.setMode(ModeCalibration)
+}
+
+// Restart はスコアをリセットしてカウントダウンからやり直す。
+func (m *Match) Restart() {
+	m.startCountdown(m.clock.Now())
+}
+
+// Update は現在時刻までゲームを進める。毎フレーム呼び出すこと。
+func (m *Match) Update() {
+	now := m.clock.Now()
+
+	// メンバーがいない、または全員が保存済みのキャリブレーションを
+	// 復元済みの場合はキャリブレーションをスキップ
+	if m.mode == ModeCalibration && (m.activeCount() == 0 || m.allCalibrated()) {
+		m.startCountdown(now)
+	}
+
+	// モード遷移
+	switch m.mode {
+	case ModeCountdown:
+		if !now.Before(m.deadline) {
+			m.startTime = now
+			m.deadline = now.Add(m.settings.Duration)
+			m.clearTargets()
+			m.nextSpawnTime = now
+			m.setMode(ModePlaying)
+		}
+	case ModePlaying:
+		if !now.Before(m.deadline) {
+			m.clearTargets()
+			m.setMode(ModeGameOver)
+			m.OnEvent(GameOver{Players: m.snapshot()})
+		}
+	}
+
+	// ターゲットのスポーンと消滅 (プレイ中のみ)
+	if m.mode == ModePlaying {
+		m.updateTargets(now)
+	}
+}
+
+func (m *Match) updateTargets(now time.Time) {
+	// 経過割合 0.0 → 1.0
+	progress := min(now.Sub(m.startTime).Seconds()/m.settings.Duration.Seconds(), 1.0)
+
+	// スポーン間隔と寿命、ターゲットの速さは設定の始めの値から終わりの値へ変わる
+	spawnInterval := lerp(m.settings.SpawnIntervalStart, m.settings.SpawnIntervalEnd, progress)
+	lifetime := lerp(m.settings.LifetimeStart, m.settings.LifetimeEnd, progress)
+	// 速さは 1 秒あたり accel ずつ変わる。スポーンした後のターゲットも同じだけ速くなる
+	accel := (m.settings.TargetSpeedEnd - m.settings.TargetSpeedStart) / m.settings.Duration.Seconds()
+	speed := m.settings.TargetSpeedStart + (m.settings.TargetSpeedEnd-m.settings.TargetSpeedStart)*progress
+
+	// スポーン
+	if now.After(m.nextSpawnTime) {
+		kind := newKind(m.rand, m.settings.SpecialRate)
+		size := m.settings.TargetRadius * kind.scale()
+		margin := size + MarkerSize/2
+		kindSpeed := kind.speed(speed)
+		motion := newMotion(m.rand,
+			schema.Point{X: margin, Y: margin},
+			schema.Point{X: m.width - margin, Y: m.height - margin},
+			kindSpeed)
+		if kindSpeed > 0 {
+			motion.Ramp = accel / kindSpeed
+		}
+		pos := motion.Position(0)
+		tgt := Target{
+			ID:        m.nextTargetID,
+			Kind:      kind,
+			X:         pos.X,
+			Y:         pos.Y,
+			Radius:    size,
+			Size:      size,
+			SpawnTime: now,
+			Lifetime:  lifetime,
+			Motion:    motion,
+			Hits:      kind.hits(),
+		}
+		m.nextTargetID++
+		m.targets = append(m.targets, tgt)
+		m.nextSpawnTime = now.Add(spawnInterval)
+		m.OnEvent(TargetSpawned{Target: tgt})
+	}
+
+	// 期限切れのターゲットを除去
+	for i := 0; i < len(m.targets); {
+		if tgt := m.targets[i]; tgt.Expired(now) {
+			m.removeTarget(i)
+			m.expired = append(m.expired, tgt)
+			m.OnEvent(TargetExpired{Target: tgt})
+		} else {
+			i++
+		}
+	}
+	// 描画と Status のために現在の位置と大きさにする
+	for i := range m.targets {
+		tgt := &m.targets[i]
+		pos := tgt.Position(now)
+		tgt.X, tgt.Y = pos.X, pos.Y
+		tgt.Radius = tgt.RadiusAt(now)
+	}
+	// 巻き戻しても届かなくなった履歴を捨てる
+	horizon := now.Add(-m.maxRewind)
+	m.expired = slices.DeleteFunc(m.expired, func(tgt Target) bool {
+		return tgt.Expired(horizon)
+	})
+}
+
+// findTarget は時刻 at に pos から半径以内にあったターゲットを探す。
+// 動くターゲットや小さくなるターゲットは at の時点の位置と半径で判定する。
+// at の時点で生きていた期限切れのターゲットも対象にするが、at より後に
+// スポーンしたターゲットは対象にしない。見つかれば expired のどちらにあったかも返す。
+func (m *Match) findTarget(pos schema.Point, at time.Time) (index int, expired bool, dist float64, ok bool) {
+	for i, tgt := range m.targets {
+		if tgt.SpawnTime.After(at) {
+			continue
+		}
+		if dist := pos.Dist(tgt.Position(at)); dist <= tgt.RadiusAt(at) {
+			return i, false, dist, true
+		}
+	}
+	for i, tgt := range m.expired {
+		if tgt.SpawnTime.After(at) || tgt.Expired(at) {
+			continue
+		}
+		if dist := pos.Dist(tgt.Position(at)); dist <= tgt.RadiusAt(at) {
+			return i, true, dist, true
+		}
+	}
+	return 0, false, 0, false
+}
+
+// hitTarget はターゲットの残りの命中数を減らし、壊れたら取り除く。
+// 命中した時点のターゲットと、壊れたかどうかを返す。
+func (m *Match) hitTarget(index int, expired bool) (Target, bool) {
+	list := &m.targets
+	if expired {
+		list = &m.expired
+	}
+	tgt := &(*list)[index]
+	tgt.Hits--
+	hit := *tgt
+	if hit.Hits > 0 {
+		return hit, false
+	}
+	if expired {
+		m.expired = slices.Delete(m.expired, index, index+1)
+	} else {
+		m.removeTarget(index)
+	}
+	return hit, true
+}
+
+func (m *Match) fire(p *Player, at time.Time) {
+	if m.mode == ModeCalibration {
+		m.calibrationShot(p)
+		return
+	}
+
+	pos := m.ScreenPosition(p.Position())
+	offscreen := pos.X < 0 || pos.Y < 0 || pos.X > m.width || pos.Y > m.height
+	if offscreen && m.mode != ModePlaying {
+		return
+	}
+
+	// プレイ中: ターゲットに命中した場合のみスコア加算。画面外への射撃は外れとして通知する
+	if m.mode == ModePlaying {
+		if index, expired, dist, ok := m.findTarget(pos, at); ok && !offscreen {
+			tgt, destroyed := m.hitTarget(index, expired)
+			points, bullseye := m.score(tgt, dist, at, destroyed)
+			combo := p.Stats.hit(tgt, bullseye, destroyed, at)
+			points *= combo
+			var extension time.Duration
+			if destroyed && tgt.Kind == TargetTime {
+				extension = TimeExtension
+				m.deadline = m.deadline.Add(extension)
+			}
+			p.Score += points
+			m.OnEvent(Hit{
+				PlayerID:  p.ID,
+				Target:    tgt,
+				Position:  pos,
+				Points:    points,
+				Bullseye:  bullseye,
+				Combo:     combo,
+				Destroyed: destroyed,
+				Extension: extension,
+				Rewind:    m.clock.Now().Sub(at),
+			})
+			return
+		}
+		p.Stats.miss()
+	}
+	m.OnEvent(Miss{PlayerID: p.ID, Position: pos})
+}
+
+func (m *Match) calibrationShot(p *Player) {
+	// 既にこの箇所のキャリブレーションを終えている、または範囲外なら無視
+	accepted := p.Calibrated == m.calibIndex && p.Calibrated < len(m.pattern)
+	if accepted {
+		p.Calibration[p.Calibrated] = p.Aim
+		p.Calibrated++
+	}
+	m.OnEvent(CalibrationShot{
+		PlayerID: p.ID,
+		Index:    m.calibIndex,
+		Accepted: accepted,
+	})
+	if accepted && p.IsCalibrated() {
+		if err := p.solveCalibration(m.pattern); err != nil {
+			// 退化したキャリブレーションは棄却し、このプレイヤーだけ最初から撃ち直す。
+			// 完了済みのプレイヤーは Calibrated が上限なので進行を妨げない。
+			p.resetCalibration(m.pattern)
+			m.calibIndex = 0
+			m.OnEvent(CalibrationRejected{
+				PlayerID: p.ID,
+				Quality:  p.CalibrationQuality,
+				Err:      err,
+			})
+			return
+		}
+		m.OnEvent(CalibrationCompleted{
+			PlayerID: p.ID,
+			Quality:  p.CalibrationQuality,
+		})
+	}
+
+	// 全員が現在のインデックスを完了したかチェック
+	allAdvanced := true
+	hasActivePlayers := false
+	for _, other := range m.players {
+		if !other.Active {
+			continue
+		}
+		hasActivePlayers = true
+		if other.Calibrated <= m.calibIndex {
+			allAdvanced = false
+			break
+		}
+	}
+	if hasActivePlayers && allAdvanced {
+		m.calibIndex++
+		if m.calibIndex >= len(m.pattern) {
+			m.startCountdown(m.clock.Now())
+		}
+	}
+}
+
+func (m *Match) startCountdown(now time.Time) {
+	for _, p := range m.players {
+		p.Score = 0
+		p.Stats = Stats{}
+	}
+	m.clearTargets()
+	m.deadline = now.Add(m.settings.Countdown)
+	m.setMode(ModeCountdown)
+}
+
+func (m *Match) setMode(mode Mode) {
+	from := m.mode
+	m.mode = mode
+	m.OnEvent(ModeChanged{From: from, To: mode})
+}
+
+func (m *Match) clearTargets() {
+	m.targets = nil
+	m.expired = nil
+}
+
+func (m *Match) removeTarget(i int) {
+	m.targets[i] = m.targets[len(m.targets)-1]
+	m.targets = m.targets[:len(m.targets)-1]
+}
+
+func (m *Match) activeCount() int {
+	count := 0
+	for _, p := range m.players {
+		if p.Active {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *Match) allCalibrated() bool {
+	for _, p := range m.players {
+		if p.Active && !p.IsCalibrated() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Match) snapshot() []Player {
+	result := make([]Player, 0, len(m.players))
+	for _, p := range m.Players() {
+		result = append(result, *p)
+	}
+	return result
+}
@@ -0,0 +1,367 @@
+package rules
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// testSettings は動かない通常のターゲットだけが出る設定。
+var testSettings = MatchSettings{
+	Duration:           60 * time.Second,
+	Countdown:          3 * time.Second,
+	SpawnIntervalStart: time.Second,
+	SpawnIntervalEnd:   250 * time.Millisecond,
+	LifetimeStart:      6 * time.Second,
+	LifetimeEnd:        2 * time.Second,
+	TargetRadius:       120,
+	BullseyeRadius:     60,
+	HitPoints:          1,
+	BullseyePoints:     5,
+}
+
+type testMatch struct {
+	*Match
+	clock  *fakeClock
+	events []Event
+}
+
+func newTestMatch(settings MatchSettings) *testMatch {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tm := &testMatch{clock: clock}
+	tm.Match = NewMatch(MatchInfo{
+		Clock:    clock,
+		Rand:     rand.New(rand.NewSource(1)),
+		Width:    1920,
+		Height:   1080,
+		Settings: settings,
+	})
+	tm.OnEvent = func(e Event) { tm.events = append(tm.events, e) }
+	return tm
+}
+
+// step は時計を d 進めて Update する。
+func (tm *testMatch) step(d time.Duration) {
+	tm.clock.Advance(d)
+	tm.Update()
+}
+
+// join は照準をそのまま正規化座標として使うプレイヤーを参加させる。
+func (tm *testMatch) join(t *testing.T, id string) {
+	t.Helper()
+	tm.Input(id, schema.Info{Name: id})
+	if err := tm.RestoreCalibration(id, Pattern4); err != nil {
+		t.Fatalf("RestoreCalibration: %v", err)
+	}
+}
+
+// startPlaying は id のプレイヤーを参加させてプレイ中まで進める。
+func (tm *testMatch) startPlaying(t *testing.T, id string) {
+	t.Helper()
+	tm.join(t, id)
+	tm.Start()
+	tm.Update()
+	tm.step(tm.Settings().Countdown)
+	if tm.Mode() != ModePlaying {
+		t.Fatalf("mode = %v, want playing", tm.Mode())
+	}
+}
+
+// spawn はターゲットが 1 つ出るまで時計を進め、そのターゲットを返す。
+func (tm *testMatch) spawn(t *testing.T) Target {
+	t.Helper()
+	for range 1000 {
+		n := len(tm.Targets())
+		tm.step(10 * time.Millisecond)
+		if len(tm.Targets()) > n {
+			return tm.Targets()[len(tm.Targets())-1]
+		}
+	}
+	t.Fatal("no target spawned")
+	return Target{}
+}
+
+// fireAt はスクリーン座標 pos を撃ち、最後のイベントを返す。
+func (tm *testMatch) fireAt(id string, pos schema.Point, at time.Time) Event {
+	tm.events = nil
+	aim := tm.NormalizedPosition(pos)
+	tm.Fire(id, aim, at)
+	if len(tm.events) == 0 {
+		return nil
+	}
+	return tm.events[len(tm.events)-1]
+}
+
+func TestMatchModeTransitions(t *testing.T) {
+	tm := newTestMatch(testSettings)
+	tm.Input("p1", schema.Info{Name: "p1"})
+
+	tm.Update()
+	if tm.Mode() != ModeWaiting {
+		t.Fatalf("mode = %v, want waiting", tm.Mode())
+	}
+	tm.Start()
+	if tm.Mode() != ModeCalibration {
+		t.Fatalf("mode = %v, want calibration", tm.Mode())
+	}
+
+	// パターンの点を生座標のまま撃つと恒等変換でキャリブレーションが終わる
+	for i, point := range Pattern4 {
+		if tm.Mode() != ModeCalibration {
+			t.Fatalf("shot %d: mode = %v, want calibration", i, tm.Mode())
+		}
+		tm.Fire("p1", point, time.Time{})
+	}
+	if tm.Mode() != ModeCountdown {
+		t.Fatalf("mode = %v, want countdown", tm.Mode())
+	}
+
+	tm.step(testSettings.Countdown - time.Millisecond)
+	if tm.Mode() != ModeCountdown {
+		t.Fatalf("mode = %v before countdown ends, want countdown", tm.Mode())
+	}
+	tm.step(time.Millisecond)
+	if tm.Mode() != ModePlaying {
+		t.Fatalf("mode = %v, want playing", tm.Mode())
+	}
+	if got := tm.Remaining(); got != testSettings.Duration {
+		t.Errorf("Remaining = %v, want %v", got, testSettings.Duration)
+	}
+
+	tm.events = nil
+	tm.step(testSettings.Duration)
+	if tm.Mode() != ModeGameOver {
+		t.Fatalf("mode = %v, want gameover", tm.Mode())
+	}
+	var gameOver *GameOver
+	for _, e := range tm.events {
+		if e, ok := e.(GameOver); ok {
+			gameOver = &e
+		}
+	}
+	if gameOver == nil || len(gameOver.Players) != 1 || gameOver.Players[0].ID != "p1" {
+		t.Errorf("GameOver event = %+v, want one result for p1", gameOver)
+	}
+	if len(tm.Targets()) != 0 {
+		t.Errorf("%d targets left after game over", len(tm.Targets()))
+	}
+
+	tm.Restart()
+	if tm.Mode() != ModeCountdown {
+		t.Errorf("mode after Restart = %v, want countdown", tm.Mode())
+	}
+}
+
+func TestMatchSkipsCalibrationWithoutPlayers(t *testing.T) {
+	tm := newTestMatch(testSettings)
+	tm.Start()
+	tm.Update()
+	if tm.Mode() != ModeCountdown {
+		t.Errorf("mode = %v, want countdown", tm.Mode())
+	}
+}
+
+func TestMatchSpawnCurve(t *testing.T) {
+	tm := newTestMatch(testSettings)
+	tm.startPlaying(t, "p1")
+	start := tm.clock.Now()
+
+	const tick = 10 * time.Millisecond
+	var spawned []Target
+	expiredAt := map[int]time.Time{}
+	for tm.Mode() == ModePlaying {
+		tm.events = nil
+		tm.step(tick)
+		for _, e := range tm.events {
+			switch e := e.(type) {
+			case TargetSpawned:
+				spawned = append(spawned, e.Target)
+			case TargetExpired:
+				expiredAt[e.Target.ID] = tm.clock.Now()
+			}
+		}
+	}
+	if len(spawned) < 10 {
+		t.Fatalf("only %d targets spawned", len(spawned))
+	}
+
+	near := func(got, want, tolerance time.Duration) bool {
+		d := got - want
+		return d >= -tolerance && d <= tolerance
+	}
+	// 始めは SpawnIntervalStart と LifetimeStart、終わりは End の値に近づく
+	first, second := spawned[0], spawned[1]
+	if got := second.SpawnTime.Sub(first.SpawnTime); !near(got, testSettings.SpawnIntervalStart, 2*tick) {
+		t.Errorf("first spawn interval = %v, want about %v", got, testSettings.SpawnIntervalStart)
+	}
+	if !near(first.Lifetime, testSettings.LifetimeStart, 50*time.Millisecond) {
+		t.Errorf("first lifetime = %v, want about %v", first.Lifetime, testSettings.LifetimeStart)
+	}
+	last, prev := spawned[len(spawned)-1], spawned[len(spawned)-2]
+	if got := last.SpawnTime.Sub(prev.SpawnTime); !near(got, testSettings.SpawnIntervalEnd, 2*tick) {
+		t.Errorf("last spawn interval = %v, want about %v", got, testSettings.SpawnIntervalEnd)
+	}
+	if !near(last.Lifetime, testSettings.LifetimeEnd, 50*time.Millisecond) {
+		t.Errorf("last lifetime = %v, want about %v", last.Lifetime, testSettings.LifetimeEnd)
+	}
+
+	// 寿命は単調に短くなり、期限切れは寿命を過ぎた最初の Update で通知される
+	for i, tgt := range spawned {
+		if i > 0 && tgt.Lifetime > spawned[i-1].Lifetime {
+			t.Errorf("target %d lifetime %v is longer than previous %v", tgt.ID, tgt.Lifetime, spawned[i-1].Lifetime)
+		}
+		if tgt.SpawnTime.Before(start) {
+			t.Errorf("target %d spawned before the match started", tgt.ID)
+		}
+		at, ok := expiredAt[tgt.ID]
+		if !ok {
+			continue
+		}
+		if age := at.Sub(tgt.SpawnTime); age <= tgt.Lifetime || age > tgt.Lifetime+tick {
+			t.Errorf("target %d expired after %v, want just after %v", tgt.ID, age, tgt.Lifetime)
+		}
+	}
+}
+
+// singleTargetSettings は 1 度に 1 つだけターゲットが出る設定。
+func singleTargetSettings() MatchSettings {
+	s := testSettings
+	s.SpawnIntervalStart = 10 * time.Second
+	s.SpawnIntervalEnd = 10 * time.Second
+	s.LifetimeStart = 2 * time.Second
+	s.LifetimeEnd = 2 * time.Second
+	return s
+}
+
+func TestMatchScoring(t *testing.T) {
+	settings := singleTargetSettings()
+	tests := []struct {
+		name     string
+		offset   float64
+		hit      bool
+		bullseye bool
+		points   int
+	}{
+		{name: "center", offset: 0, hit: true, bullseye: true, points: settings.BullseyePoints},
+		{name: "bullseye edge", offset: settings.BullseyeRadius - 1, hit: true, bullseye: true, points: settings.BullseyePoints},
+		{name: "ring", offset: settings.BullseyeRadius + 1, hit: true, points: settings.HitPoints},
+		{name: "target edge", offset: settings.TargetRadius - 1, hit: true, points: settings.HitPoints},
+		{name: "outside", offset: settings.TargetRadius + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := newTestMatch(settings)
+			tm.startPlaying(t, "p1")
+			tgt := tm.spawn(t)
+
+			event := tm.fireAt("p1", schema.Point{X: tgt.X + tt.offset, Y: tgt.Y}, time.Time{})
+			player, _ := tm.Player("p1")
+			if !tt.hit {
+				if _, ok := event.(Miss); !ok {
+					t.Fatalf("event = %#v, want Miss", event)
+				}
+				if player.Score != 0 {
+					t.Errorf("score = %d, want 0", player.Score)
+				}
+				return
+			}
+			hit, ok := event.(Hit)
+			if !ok {
+				t.Fatalf("event = %#v, want Hit", event)
+			}
+			if hit.Bullseye != tt.bullseye || hit.Points != tt.points {
+				t.Errorf("Hit bullseye=%v points=%d, want bullseye=%v points=%d",
+					hit.Bullseye, hit.Points, tt.bullseye, tt.points)
+			}
+			if player.Score != tt.points {
+				t.Errorf("score = %d, want %d", player.Score, tt.points)
+			}
+			if len(tm.Targets()) != 0 {
+				t.Errorf("target not removed after hit")
+			}
+		})
+	}
+}
+
+func TestMatchRewindHitsExpiredTarget(t *testing.T) {
+	settings := singleTargetSettings()
+	tm := newTestMatch(settings)
+	tm.startPlaying(t, "p1")
+	tgt := tm.spawn(t)
+
+	// 寿命を過ぎて Update で取り除かれた後に、生きていた時刻の射撃が届く
+	tm.clock.now = tgt.SpawnTime.Add(tgt.Lifetime + 50*time.Millisecond)
+	tm.Update()
+	if len(tm.Targets()) != 0 {
+		t.Fatalf("target still alive after its lifetime")
+	}
+	shotAt := tgt.SpawnTime.Add(tgt.Lifetime - 10*time.Millisecond)
+	event := tm.fireAt("p1", schema.Point{X: tgt.X, Y: tgt.Y}, shotAt)
+	hit, ok := event.(Hit)
+	if !ok {
+		t.Fatalf("event = %#v, want Hit", event)
+	}
+	if hit.Target.ID != tgt.ID {
+		t.Errorf("hit target %d, want %d", hit.Target.ID, tgt.ID)
+	}
+	if want := tm.clock.Now().Sub(shotAt); hit.Rewind != want {
+		t.Errorf("Rewind = %v, want %v", hit.Rewind, want)
+	}
+
+	// 同じターゲットは 2 度当たらない
+	if event := tm.fireAt("p1", schema.Point{X: tgt.X, Y: tgt.Y}, shotAt); event == nil {
+		t.Fatal("no event for second shot")
+	} else if _, ok := event.(Miss); !ok {
+		t.Errorf("second shot event = %#v, want Miss", event)
+	}
+}
+
+func TestMatchRewindIsLimited(t *testing.T) {
+	settings := singleTargetSettings()
+	tm := newTestMatch(settings)
+	tm.startPlaying(t, "p1")
+	tgt := tm.spawn(t)
+
+	// MaxRewind より古い射撃は MaxRewind 前まで巻き戻すだけなので、期限切れのターゲットには当たらない
+	tm.clock.now = tgt.SpawnTime.Add(tgt.Lifetime + DefaultMaxRewind + 50*time.Millisecond)
+	tm.Update()
+	shotAt := tgt.SpawnTime.Add(tgt.Lifetime - 10*time.Millisecond)
+	if event := tm.fireAt("p1", schema.Point{X: tgt.X, Y: tgt.Y}, shotAt); event == nil {
+		t.Fatal("no event for shot")
+	} else if _, ok := event.(Miss); !ok {
+		t.Errorf("event = %#v, want Miss", event)
+	}
+}
+
+func TestMatchOffscreenShotIsMiss(t *testing.T) {
+	tm := newTestMatch(singleTargetSettings())
+	tm.startPlaying(t, "p1")
+	tgt := tm.spawn(t)
+	tm.fireAt("p1", schema.Point{X: tgt.X, Y: tgt.Y}, time.Time{})
+
+	event := tm.fireAt("p1", schema.Point{X: -100, Y: 540}, time.Time{})
+	if _, ok := event.(Miss); !ok {
+		t.Fatalf("event = %#v, want Miss", event)
+	}
+	player, _ := tm.Player("p1")
+	if player.Stats.Shots != 2 || player.Stats.Hits != 1 {
+		t.Errorf("shots=%d hits=%d, want shots=2 hits=1", player.Stats.Shots, player.Stats.Hits)
+	}
+	if player.Stats.Streak != 0 {
+		t.Errorf("streak = %d, want 0 after an off-screen shot", player.Stats.Streak)
+	}
+}
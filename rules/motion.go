@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"math"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// MotionKind はターゲットの動き方。
+type MotionKind int
+
+const (
+	// MotionStatic はスポーンした位置から動かない。
+	MotionStatic MotionKind = iota
+	// MotionLinear は一定の速度で直進し、画面の端で跳ね返る。
+	MotionLinear
+	// MotionSine は直進しながら進行方向と直角に揺れる。
+	MotionSine
+	// MotionOrbit は Origin を中心に円を描く。
+	MotionOrbit
+)
+
+func (k MotionKind) String() string {
+	switch k {
+	case MotionStatic:
+		return "static"
+	case MotionLinear:
+		return "linear"
+	case MotionSine:
+		return "sine"
+	case MotionOrbit:
+		return "orbit"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// sineAmplitude と orbitRadius の範囲 (スクリーンピクセル)。
+	minSineAmplitude = 40
+	maxSineAmplitude = 120
+	minOrbitRadius   = 60
+	maxOrbitRadius   = 180
+	// sineFrequency は MotionSine が 1 秒に揺れる回数。
+	sineFrequency = 0.5
+)
+
+// Motion はスポーンからの経過時間だけで位置が決まる軌道。
+// 巻き戻して命中判定できるよう、途中の状態は持たない。
+type Motion struct {
+	Kind MotionKind
+	// Origin はスポーンした位置。MotionOrbit では円の中心。
+	Origin schema.Point
+	// Velocity は 1 秒あたりの移動量 (スクリーンピクセル)。
+	Velocity schema.Point
+	// Amplitude は MotionSine の揺れ幅、MotionOrbit の半径。
+	Amplitude float64
+	// Angular は MotionOrbit の角速度 (rad/s)。
+	Angular float64
+	Phase   float64
+	// Ramp は 1 秒ごとに速さが初速の何倍ずつ変わるか。試合が進むにつれてスポーン後も速くなる。
+	// 負なら遅くなり、止まったところで止まったままになる。
+	Ramp float64
+	// Min, Max はターゲットの中心が動ける範囲。端に着いたら跳ね返る。
+	Min, Max schema.Point
+}
+
+// travel はスポーンから t 秒の間に初速のまま動いたとしたら何秒分進んだかを返す。
+func (m Motion) travel(t float64) float64 {
+	if m.Ramp < 0 {
+		t = min(t, -1/m.Ramp)
+	}
+	return t + m.Ramp*t*t/2
+}
+
+// Position はスポーンから elapsed 経ったときの位置を返す。
+func (m Motion) Position(elapsed time.Duration) schema.Point {
+	t := max(elapsed.Seconds(), 0)
+	d := m.travel(t)
+	switch m.Kind {
+	case MotionLinear:
+		return schema.Point{
+			X: bounce(m.Origin.X+m.Velocity.X*d, m.Min.X, m.Max.X),
+			Y: bounce(m.Origin.Y+m.Velocity.Y*d, m.Min.Y, m.Max.Y),
+		}
+	case MotionSine:
+		// 揺れても範囲からはみ出さないよう、進む範囲を揺れ幅だけ狭める
+		speed := math.Hypot(m.Velocity.X, m.Velocity.Y)
+		if speed == 0 {
+			return m.Origin
+		}
+		nx, ny := -m.Velocity.Y/speed, m.Velocity.X/speed
+		offset := m.Amplitude * math.Sin(2*math.Pi*sineFrequency*t+m.Phase)
+		return schema.Point{
+			X: bounce(m.Origin.X+m.Velocity.X*d, m.Min.X+m.Amplitude, m.Max.X-m.Amplitude) + nx*offset,
+			Y: bounce(m.Origin.Y+m.Velocity.Y*d, m.Min.Y+m.Amplitude, m.Max.Y-m.Amplitude) + ny*offset,
+		}
+	case MotionOrbit:
+		angle := m.Phase + m.Angular*d
+		return schema.Point{
+			X: m.Origin.X + m.Amplitude*math.Cos(angle),
+			Y: m.Origin.Y + m.Amplitude*math.Sin(angle),
+		}
+	default:
+		return m.Origin
+	}
+}
+
+// bounce は lo と hi の間を往復する点として v を折り返す。
+func bounce(v, lo, hi float64) float64 {
+	width := hi - lo
+	if width <= 0 {
+		return (lo + hi) / 2
+	}
+	u := math.Mod(v-lo, 2*width)
+	if u < 0 {
+		u += 2 * width
+	}
+	if u > width {
+		u = 2*width - u
+	}
+	return lo + u
+}
+
+// newMotion は lo から hi の範囲にスポーンするターゲットの軌道をランダムに選ぶ。
+// speed が 0 なら動かない。
+func newMotion(rnd Rand, lo, hi schema.Point, speed float64) Motion {
+	m := Motion{
+		Kind: MotionStatic,
+		Origin: schema.Point{
+			X: lo.X + rnd.Float64()*(hi.X-lo.X),
+			Y: lo.Y + rnd.Float64()*(hi.Y-lo.Y),
+		},
+		Min: lo,
+		Max: hi,
+	}
+	if speed <= 0 {
+		return m
+	}
+	kind := MotionKind(1 + int(rnd.Float64()*3)%3)
+	direction := rnd.Float64() * 2 * math.Pi
+	velocity := schema.Point{X: speed * math.Cos(direction), Y: speed * math.Sin(direction)}
+	phase := rnd.Float64() * 2 * math.Pi
+	// 揺れ幅と半径は範囲の半分を超えないようにする
+	room := math.Min(hi.X-lo.X, hi.Y-lo.Y) / 2
+	switch kind {
+	case MotionSine:
+		amplitude := math.Min(minSineAmplitude+rnd.Float64()*(maxSineAmplitude-minSineAmplitude), room)
+		if amplitude <= 0 {
+			return m
+		}
+		m.Amplitude = amplitude
+		m.Origin.X = bounce(m.Origin.X, lo.X+amplitude, hi.X-amplitude)
+		m.Origin.Y = bounce(m.Origin.Y, lo.Y+amplitude, hi.Y-amplitude)
+	case MotionOrbit:
+		radius := math.Min(minOrbitRadius+rnd.Float64()*(maxOrbitRadius-minOrbitRadius), room)
+		if radius <= 0 {
+			return m
+		}
+		// 円が範囲に収まるよう中心を寄せる
+		m.Amplitude = radius
+		m.Origin.X = math.Min(math.Max(m.Origin.X, lo.X+radius), hi.X-radius)
+		m.Origin.Y = math.Min(math.Max(m.Origin.Y, lo.Y+radius), hi.Y-radius)
+		m.Angular = speed / radius
+		if rnd.Float64() < 0.5 {
+			m.Angular = -m.Angular
+		}
+	}
+	m.Kind, m.Velocity, m.Phase = kind, velocity, phase
+	return m
+}
@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func nearPoint(a, b schema.Point) bool {
+	return math.Abs(a.X-b.X) < 1e-6 && math.Abs(a.Y-b.Y) < 1e-6
+}
+
+func TestLinearMotionBounces(t *testing.T) {
+	m := Motion{
+		Kind:     MotionLinear,
+		Origin:   schema.Point{X: 100, Y: 50},
+		Velocity: schema.Point{X: 100, Y: 0},
+		Min:      schema.Point{X: 0, Y: 0},
+		Max:      schema.Point{X: 200, Y: 100},
+	}
+	tests := []struct {
+		elapsed float64
+		x       float64
+	}{
+		{elapsed: 0, x: 100},
+		{elapsed: 0.5, x: 150},
+		{elapsed: 1, x: 200},   // 右の壁
+		{elapsed: 1.5, x: 150}, // 跳ね返って戻る
+		{elapsed: 3, x: 0},     // 左の壁
+		{elapsed: 3.25, x: 25},
+		{elapsed: 5, x: 200},
+	}
+	for _, tt := range tests {
+		got := m.Position(seconds(tt.elapsed))
+		if !nearPoint(got, schema.Point{X: tt.x, Y: 50}) {
+			t.Errorf("Position(%vs) = %v, want (%v, 50)", tt.elapsed, got, tt.x)
+		}
+	}
+}
+
+func TestMotionStaysInBounds(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	lo, hi := schema.Point{X: 220, Y: 220}, schema.Point{X: 1700, Y: 860}
+	for i := range 200 {
+		m := newMotion(rnd, lo, hi, 50+rnd.Float64()*400)
+		for elapsed := time.Duration(0); elapsed < 10*time.Second; elapsed += 50 * time.Millisecond {
+			p := m.Position(elapsed)
+			if p.X < lo.X-1e-9 || p.X > hi.X+1e-9 || p.Y < lo.Y-1e-9 || p.Y > hi.Y+1e-9 {
+				t.Fatalf("motion %d (%v) at %v: %v out of bounds", i, m.Kind, elapsed, p)
+			}
+		}
+	}
+}
+
+func TestOrbitPeriod(t *testing.T) {
+	const radius, speed = 100.0, 200.0
+	m := Motion{
+		Kind:      MotionOrbit,
+		Origin:    schema.Point{X: 500, Y: 500},
+		Amplitude: radius,
+		Angular:   speed / radius,
+		Phase:     0.3,
+	}
+	period := 2 * math.Pi * radius / speed
+	start := m.Position(0)
+	if d := start.Dist(m.Origin); math.Abs(d-radius) > 1e-9 {
+		t.Errorf("distance from center = %v, want %v", d, radius)
+	}
+	for _, turns := range []float64{1, 2, 5} {
+		if got := m.Position(seconds(period * turns)); !nearPoint(got, start) {
+			t.Errorf("after %v turns: %v, want %v", turns, got, start)
+		}
+	}
+	half := m.Position(seconds(period / 2))
+	want := schema.Point{X: 2*m.Origin.X - start.X, Y: 2*m.Origin.Y - start.Y}
+	if !nearPoint(half, want) {
+		t.Errorf("after half a turn: %v, want the opposite point %v", half, want)
+	}
+}
+
+func TestMotionRamp(t *testing.T) {
+	m := Motion{
+		Kind:     MotionLinear,
+		Velocity: schema.Point{X: 100},
+		Ramp:     0.5, // 1 秒ごとに初速の半分ずつ速くなる
+		Min:      schema.Point{X: -1e6},
+		Max:      schema.Point{X: 1e6},
+	}
+	// 2 秒で速さは 100 → 200、進んだ距離は台形の面積 300
+	if got := m.Position(2 * time.Second); math.Abs(got.X-300) > 1e-9 {
+		t.Errorf("accelerating: x = %v, want 300", got.X)
+	}
+
+	m.Ramp = -0.5
+	// 2 秒で止まり、その後は動かない
+	for _, elapsed := range []time.Duration{2 * time.Second, 5 * time.Second} {
+		if got := m.Position(elapsed); math.Abs(got.X-100) > 1e-9 {
+			t.Errorf("decelerating: x at %v = %v, want 100", elapsed, got.X)
+		}
+	}
+}
+
+func TestMatchTargetsSpeedUp(t *testing.T) {
+	settings := testSettings
+	settings.TargetSpeedStart = 100
+	settings.TargetSpeedEnd = 400
+	tm := newTestMatch(settings)
+	tm.startPlaying(t, "p1")
+	tm.step(10 * time.Second)
+	tgt := tm.Targets()[len(tm.Targets())-1]
+
+	// ターゲットの速さは試合の経過に合わせて 1 秒あたり (End-Start)/Duration ずつ上がる
+	accel := (settings.TargetSpeedEnd - settings.TargetSpeedStart) / settings.Duration.Seconds()
+	speed := math.Hypot(tgt.Motion.Velocity.X, tgt.Motion.Velocity.Y)
+	if got := tgt.Motion.Ramp * speed; math.Abs(got-accel) > 1e-9 {
+		t.Errorf("acceleration = %v, want %v", got, accel)
+	}
+}
@@ -0,0 +1,77 @@
+package rules
+
+import (
+	"github.com/nobonobo/gun-shooter/calib"
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// Player は Match に参加しているプレイヤーの状態。
+type Player struct {
+	ID     string
+	Name   string
+	Device string
+	Active bool
+	Fire   bool
+	Score  int
+	// Muted のプレイヤーの射撃はキャリブレーション以外では無視する。
+	Muted bool
+	// Team はチーム戦での所属チーム。0 ならチームに属さない。
+	Team int
+	// Stats はこの Match のプレイ中の成績。
+	Stats Stats
+
+	// Aim はスコープから届いた生のマーカー座標。
+	Aim schema.Point
+
+	// Calibration[i] はパターンの i 番目のターゲットを狙った際の生マーカー座標。
+	Calibration []schema.Point
+	Calibrated  int
+
+	// Homography はキャリブレーション完了時に一度だけ求める生座標→正規化座標の変換。
+	Homography         calib.Homography
+	CalibrationQuality float64
+	// CalibrationResiduals[i] は求めた変換で Calibration[i] を写した点と
+	// ターゲットとの距離（正規化座標）。
+	CalibrationResiduals []float64
+}
+
+// IsCalibrated はキャリブレーションが完了しているかを返す。
+func (p *Player) IsCalibrated() bool {
+	return len(p.Calibration) > 0 && p.Calibrated >= len(p.Calibration)
+}
+
+// Position は現在の照準をキャリブレーション済みの正規化座標 (0-1) で返す。
+func (p *Player) Position() schema.Point {
+	return p.Calibrate(p.Aim)
+}
+
+// Calibrate は生座標 raw を正規化座標 (0-1) に変換する。
+// キャリブレーションが完了するまでは raw をそのまま返す。
+func (p *Player) Calibrate(raw schema.Point) schema.Point {
+	if !p.IsCalibrated() {
+		return raw
+	}
+	return p.Homography.Apply(raw)
+}
+
+// solveCalibration は集めた全点から最小二乗で射影変換を求める。
+func (p *Player) solveCalibration(pattern CalibrationPattern) error {
+	h, quality, err := calib.Solve(p.Calibration, pattern)
+	p.CalibrationQuality = quality
+	if err != nil {
+		return err
+	}
+	if quality < MinCalibrationQuality {
+		return calib.ErrDegenerate
+	}
+	p.Homography = h
+	p.CalibrationResiduals = h.Residuals(p.Calibration, pattern)
+	return nil
+}
+
+func (p *Player) resetCalibration(pattern CalibrationPattern) {
+	p.Calibration = make([]schema.Point, len(pattern))
+	p.Calibrated = 0
+	p.Homography = calib.Identity()
+	p.CalibrationResiduals = nil
+}
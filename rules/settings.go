@@ -0,0 +1,157 @@
+package rules
+
+import (
+	"time"
+)
+
+// MatchSettings は 1 回の Match の長さと難易度。
+// 間隔と寿命は試合の始めの値から終わりの値まで経過時間に比例して変わる。
+type MatchSettings struct {
+	Duration  time.Duration `json:"duration"`
+	Countdown time.Duration `json:"countdown"`
+	// SpawnIntervalStart, SpawnIntervalEnd はターゲットが出現する間隔。
+	SpawnIntervalStart time.Duration `json:"spawnIntervalStart"`
+	SpawnIntervalEnd   time.Duration `json:"spawnIntervalEnd"`
+	// LifetimeStart, LifetimeEnd はターゲットが撃たれずに残る時間。
+	LifetimeStart time.Duration `json:"lifetimeStart"`
+	LifetimeEnd   time.Duration `json:"lifetimeEnd"`
+	// TargetRadius, BullseyeRadius はスクリーンのピクセル単位の半径。
+	TargetRadius   float64 `json:"targetRadius"`
+	BullseyeRadius float64 `json:"bullseyeRadius"`
+	HitPoints      int     `json:"hitPoints"`
+	BullseyePoints int     `json:"bullseyePoints"`
+	// TargetSpeedStart, TargetSpeedEnd はスポーンするターゲットの 1 秒あたりの移動量 (ピクセル)。
+	// 0 なら動かないターゲットだけが出る。
+	TargetSpeedStart float64 `json:"targetSpeedStart"`
+	TargetSpeedEnd   float64 `json:"targetSpeedEnd"`
+	// SpecialRate はスポーンするターゲットのうち特殊なターゲット (TargetKind) の割合 (0-1)。
+	SpecialRate float64 `json:"specialRate"`
+}
+
+// MatchPreset は名前の付いた MatchSettings。
+type MatchPreset struct {
+	Name     string
+	Settings MatchSettings
+}
+
+// DefaultMatchSettings は Normal プリセットと同じ設定。
+var DefaultMatchSettings = MatchSettings{
+	Duration:           60 * time.Second,
+	Countdown:          3 * time.Second,
+	SpawnIntervalStart: time.Second,
+	SpawnIntervalEnd:   333 * time.Millisecond,
+	LifetimeStart:      6 * time.Second,
+	LifetimeEnd:        2 * time.Second,
+	TargetRadius:       120,
+	BullseyeRadius:     60,
+	HitPoints:          1,
+	BullseyePoints:     5,
+	TargetSpeedStart:   0,
+	TargetSpeedEnd:     150,
+	SpecialRate:        0.2,
+}
+
+// MatchPresets は設定画面で選べるプリセット。
+var MatchPresets = []MatchPreset{
+	{Name: "Easy", Settings: MatchSettings{
+		Duration:           90 * time.Second,
+		Countdown:          5 * time.Second,
+		SpawnIntervalStart: 1200 * time.Millisecond,
+		SpawnIntervalEnd:   600 * time.Millisecond,
+		LifetimeStart:      8 * time.Second,
+		LifetimeEnd:        4 * time.Second,
+		TargetRadius:       160,
+		BullseyeRadius:     80,
+		HitPoints:          1,
+		BullseyePoints:     3,
+		TargetSpeedStart:   0,
+		TargetSpeedEnd:     60,
+		SpecialRate:        0.1,
+	}},
+	{Name: "Normal", Settings: DefaultMatchSettings},
+	{Name: "Hard", Settings: MatchSettings{
+		Duration:           60 * time.Second,
+		Countdown:          3 * time.Second,
+		SpawnIntervalStart: 800 * time.Millisecond,
+		SpawnIntervalEnd:   250 * time.Millisecond,
+		LifetimeStart:      4 * time.Second,
+		LifetimeEnd:        1200 * time.Millisecond,
+		TargetRadius:       90,
+		BullseyeRadius:     40,
+		HitPoints:          1,
+		BullseyePoints:     5,
+		TargetSpeedStart:   60,
+		TargetSpeedEnd:     240,
+		SpecialRate:        0.3,
+	}},
+	// Party は短時間にターゲットが大量に出る、見ている側も楽しいモード。
+	{Name: "Party", Settings: MatchSettings{
+		Duration:           45 * time.Second,
+		Countdown:          3 * time.Second,
+		SpawnIntervalStart: 500 * time.Millisecond,
+		SpawnIntervalEnd:   200 * time.Millisecond,
+		LifetimeStart:      5 * time.Second,
+		LifetimeEnd:        2 * time.Second,
+		TargetRadius:       140,
+		BullseyeRadius:     70,
+		HitPoints:          2,
+		BullseyePoints:     10,
+		TargetSpeedStart:   80,
+		TargetSpeedEnd:     200,
+		SpecialRate:        0.5,
+	}},
+}
+
+// PresetName は s と同じ内容のプリセットの名前を返す。どれとも違えば "Custom"。
+func (s MatchSettings) PresetName() string {
+	for _, preset := range MatchPresets {
+		if preset.Settings == s {
+			return preset.Name
+		}
+	}
+	return "Custom"
+}
+
+// Normalize は 0 以下の項目を DefaultMatchSettings の値で置き換え、
+// 中心の半径がターゲットの半径を超えないようにする。ターゲットの速さと特殊なターゲットの割合は 0 を残す。
+// ゼロ値や壊れた保存データからでも Match を進められるようにするためのもの。
+func (s MatchSettings) Normalize() MatchSettings {
+	d := DefaultMatchSettings
+	if s == (MatchSettings{}) {
+		return d
+	}
+	for _, f := range []struct{ v, def *time.Duration }{
+		{&s.Duration, &d.Duration},
+		{&s.Countdown, &d.Countdown},
+		{&s.SpawnIntervalStart, &d.SpawnIntervalStart},
+		{&s.SpawnIntervalEnd, &d.SpawnIntervalEnd},
+		{&s.LifetimeStart, &d.LifetimeStart},
+		{&s.LifetimeEnd, &d.LifetimeEnd},
+	} {
+		if *f.v <= 0 {
+			*f.v = *f.def
+		}
+	}
+	if s.TargetRadius <= 0 {
+		s.TargetRadius = d.TargetRadius
+	}
+	if s.BullseyeRadius <= 0 {
+		s.BullseyeRadius = d.BullseyeRadius
+	}
+	s.BullseyeRadius = min(s.BullseyeRadius, s.TargetRadius)
+	if s.HitPoints <= 0 {
+		s.HitPoints = d.HitPoints
+	}
+	if s.BullseyePoints <= 0 {
+		s.BullseyePoints = d.BullseyePoints
+	}
+	s.TargetSpeedStart = max(s.TargetSpeedStart, 0)
+	s.TargetSpeedEnd = max(s.TargetSpeedEnd, 0)
+	s.SpecialRate = min(max(s.SpecialRate, 0), 1)
+	return s
+}
+
+// lerp は progress (0-1) に応じて from から to までの間の値を返す。
+func lerp(from, to time.Duration, progress float64) time.Duration {
+	return from + time.Duration(float64(to-from)*progress)
+}
@@ -0,0 +1,75 @@
+package rules
+
+import (
+	"time"
+)
+
+const (
+	// comboStep 回続けて当てるごとにコンボの倍率が 1 上がる。
+	comboStep = 5
+	// maxCombo はコンボの倍率の上限。
+	maxCombo = 4
+)
+
+// Stats はプレイ中の射撃の成績。カウントダウンに入るたびにリセットする。
+type Stats struct {
+	// Shots はプレイ中に撃った回数。画面外への射撃も外れとして数える。
+	Shots int
+	// Hits はターゲットに当たった回数。TargetPenalty への命中は含めない。
+	Hits      int
+	Bullseyes int
+	// Streak は今続いている連続命中数。外すか TargetPenalty に当てると 0 に戻る。
+	Streak     int
+	BestStreak int
+	// Destroyed は壊したターゲットの数。ReactionTotal はそれらのスポーンから壊すまでの時間の合計。
+	Destroyed     int
+	ReactionTotal time.Duration
+}
+
+// Accuracy は命中率 (0-1) を返す。まだ撃っていなければ 0。
+func (s Stats) Accuracy() float64 {
+	if s.Shots == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Shots)
+}
+
+// AverageReaction はターゲットがスポーンしてから壊すまでの平均時間を返す。
+func (s Stats) AverageReaction() time.Duration {
+	if s.Destroyed == 0 {
+		return 0
+	}
+	return s.ReactionTotal / time.Duration(s.Destroyed)
+}
+
+// Combo は今の連続命中数での得点の倍率を返す。
+func (s Stats) Combo() int {
+	return min(1+s.Streak/comboStep, maxCombo)
+}
+
+// miss は外れた射撃を記録する。
+func (s *Stats) miss() {
+	s.Shots++
+	s.Streak = 0
+}
+
+// hit はターゲットへの命中を記録し、この命中に掛けるコンボの倍率を返す。
+// 倍率にはこの命中自体も数える。
+func (s *Stats) hit(tgt Target, bullseye, destroyed bool, at time.Time) int {
+	if tgt.Kind == TargetPenalty {
+		s.miss()
+		return 1
+	}
+	s.Shots++
+	s.Hits++
+	if bullseye {
+		s.Bullseyes++
+	}
+	s.Streak++
+	s.BestStreak = max(s.BestStreak, s.Streak)
+	if destroyed {
+		s.Destroyed++
+		s.ReactionTotal += at.Sub(tgt.SpawnTime)
+	}
+	return s.Combo()
+}
@@ -0,0 +1,33 @@
+package rules
+
+import (
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// Target は画面上に出現する的。座標はスクリーンピクセル。
+type Target struct {
+	ID   int
+	Kind TargetKind
+	// X, Y, Radius は最後に Match を Update した時点の位置と半径。描画と Status に使う。
+	X, Y   float64
+	Radius float64
+	// Size はスポーンしたときの半径。
+	Size      float64
+	SpawnTime time.Time
+	Lifetime  time.Duration
+	Motion    Motion
+	// Hits は壊すまでに必要な残りの命中数。
+	Hits int
+}
+
+// Expired は now の時点で寿命を過ぎているかを返す。
+func (t Target) Expired(now time.Time) bool {
+	return now.Sub(t.SpawnTime) > t.Lifetime
+}
+
+// Position は時刻 at の位置を返す。命中判定は射撃時刻のこの位置で行う。
+func (t Target) Position(at time.Time) schema.Point {
+	return t.Motion.Position(at.Sub(t.SpawnTime))
+}
@@ -0,0 +1,121 @@
+package rules
+
+import (
+	"maps"
+	"slices"
+)
+
+// MaxTeams はチーム戦で分けられるチームの数の上限。
+// チームは 1 から MaxTeams までの番号で表し、0 はどのチームにも属さないことを表す。
+const MaxTeams = 4
+
+// TeamScore は 1 チーム分の合計スコア。
+type TeamScore struct {
+	Team  int
+	Score int
+	// Players はチームのメンバーをスコアの高い順に並べたもの。
+	// 途中で抜けたプレイヤーもスコアが合計に入っているので含める。
+	Players []*Player
+}
+
+// SetTeam はプレイヤーのチームを変える。範囲外の番号はチームなしにする。
+func (m *Match) SetTeam(id string, team int) {
+	if team < 0 || team > MaxTeams {
+		team = 0
+	}
+	if p, ok := m.players[id]; ok {
+		p.Team = team
+	}
+}
+
+// TeamScores はメンバーのいるチームの合計スコアを高い順に返す。
+// 同点ならチームの番号順。チームに属するプレイヤーがいなければ nil。
+func (m *Match) TeamScores() []TeamScore {
+	var result []TeamScore
+	for _, p := range m.Players() {
+		if p.Team == 0 {
+			continue
+		}
+		i := slices.IndexFunc(result, func(s TeamScore) bool { return s.Team == p.Team })
+		if i < 0 {
+			result = append(result, TeamScore{Team: p.Team})
+			i = len(result) - 1
+		}
+		result[i].Score += p.Score
+		result[i].Players = append(result[i].Players, p)
+	}
+	for _, s := range result {
+		slices.SortStableFunc(s.Players, func(a, b *Player) int {
+			return b.Score - a.Score
+		})
+	}
+	slices.SortFunc(result, func(a, b TeamScore) int {
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return a.Team - b.Team
+	})
+	return result
+}
+
+// BalanceTeams は current (ID → 今のチーム) を teams 個のチームの人数差が 1 以下になるよう分け直す。
+// 今のチームにいるプレイヤーはなるべく動かさず、人数が多すぎるチームから ID 順で後ろの人だけを移す。
+// チームに入っていないか範囲外のチームのプレイヤーは人数の少ないチームに入れる。
+func BalanceTeams(current map[string]int, teams int) map[string]int {
+	result := make(map[string]int, len(current))
+	if teams <= 0 {
+		for id := range current {
+			result[id] = 0
+		}
+		return result
+	}
+	teams = min(teams, MaxTeams)
+
+	members := make([][]string, teams+1)
+	var pending []string
+	for _, id := range slices.Sorted(maps.Keys(current)) {
+		if team := current[id]; team >= 1 && team <= teams {
+			members[team] = append(members[team], id)
+		} else {
+			pending = append(pending, id)
+		}
+	}
+
+	// 人数の多いチームから順に、n%teams 個のチームだけが 1 人多くなれる
+	order := make([]int, teams)
+	for i := range order {
+		order[i] = i + 1
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return len(members[b]) - len(members[a])
+	})
+	size, extra := len(current)/teams, len(current)%teams
+	capacity := make([]int, teams+1)
+	for i, team := range order {
+		capacity[team] = size
+		if i < extra {
+			capacity[team]++
+		}
+		if len(members[team]) > capacity[team] {
+			pending = append(pending, members[team][capacity[team]:]...)
+			members[team] = members[team][:capacity[team]]
+		}
+	}
+	// 空きのあるチームのうち人数の最も少ないチームに入れる
+	for _, id := range pending {
+		team := 0
+		for t := 1; t <= teams; t++ {
+			if len(members[t]) < capacity[t] && (team == 0 || len(members[t]) < len(members[team])) {
+				team = t
+			}
+		}
+		members[team] = append(members[team], id)
+	}
+
+	for team := 1; team <= teams; team++ {
+		for _, id := range members[team] {
+			result[id] = team
+		}
+	}
+	return result
+}
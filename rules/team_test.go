@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+func TestBalanceTeams(t *testing.T) {
+	tests := []struct {
+		name    string
+		current map[string]int
+		teams   int
+		want    map[string]int
+	}{
+		{
+			name:    "no teams",
+			current: map[string]int{"a": 1, "b": 2},
+			teams:   0,
+			want:    map[string]int{"a": 0, "b": 0},
+		},
+		{
+			name:    "unassigned",
+			current: map[string]int{"a": 0, "b": 0, "c": 0, "d": 0},
+			teams:   2,
+			want:    map[string]int{"a": 1, "b": 2, "c": 1, "d": 2},
+		},
+		{
+			name:    "already balanced",
+			current: map[string]int{"a": 2, "b": 2, "c": 1, "d": 1},
+			teams:   2,
+			want:    map[string]int{"a": 2, "b": 2, "c": 1, "d": 1},
+		},
+		{
+			name:    "moves only the excess",
+			current: map[string]int{"a": 1, "b": 1, "c": 1, "d": 1, "e": 2},
+			teams:   2,
+			want:    map[string]int{"a": 1, "b": 1, "c": 1, "d": 2, "e": 2},
+		},
+		{
+			name:    "newcomer joins the smaller team",
+			current: map[string]int{"a": 1, "b": 1, "c": 2, "d": 0},
+			teams:   2,
+			want:    map[string]int{"a": 1, "b": 1, "c": 2, "d": 2},
+		},
+		{
+			name:    "fewer teams",
+			current: map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+			teams:   2,
+			want:    map[string]int{"a": 1, "b": 2, "c": 1, "d": 2},
+		},
+		{
+			name:    "more teams than players",
+			current: map[string]int{"a": 1, "b": 1, "c": 1, "d": 1},
+			teams:   3,
+			want:    map[string]int{"a": 1, "b": 1, "c": 2, "d": 3},
+		},
+		{
+			name:    "capped at MaxTeams",
+			current: map[string]int{"a": 0, "b": 0, "c": 0, "d": 0, "e": 0},
+			teams:   MaxTeams + 2,
+			want:    map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BalanceTeams(tt.current, tt.teams)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("BalanceTeams = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalanceTeamsSizes(t *testing.T) {
+	for players := 0; players <= 12; players++ {
+		for teams := 1; teams <= MaxTeams; teams++ {
+			// 全員が同じチームにいる偏った状態から始める
+			current := map[string]int{}
+			for i := range players {
+				current[fmt.Sprintf("p%02d", i)] = 1
+			}
+			counts := map[int]int{}
+			for _, team := range BalanceTeams(current, teams) {
+				counts[team]++
+			}
+			lo, hi := players, 0
+			for team := 1; team <= teams; team++ {
+				lo, hi = min(lo, counts[team]), max(hi, counts[team])
+			}
+			if hi-lo > 1 {
+				t.Errorf("%d players in %d teams: sizes %v", players, teams, counts)
+			}
+		}
+	}
+}
+
+func TestTeamScores(t *testing.T) {
+	m := NewMatch(MatchInfo{Width: 1920, Height: 1080})
+	for id, team := range map[string]int{"a": 1, "b": 2, "c": 2, "d": 0} {
+		m.Input(id, schema.Info{Name: id})
+		m.SetTeam(id, team)
+	}
+	for id, score := range map[string]int{"a": 10, "b": 3, "c": 4, "d": 100} {
+		p, _ := m.Player(id)
+		p.Score = score
+	}
+	scores := m.TeamScores()
+	if len(scores) != 2 {
+		t.Fatalf("TeamScores = %+v, want 2 teams", scores)
+	}
+	if scores[0].Team != 1 || scores[0].Score != 10 || scores[1].Team != 2 || scores[1].Score != 7 {
+		t.Errorf("TeamScores = %+v, want team 1 (10) then team 2 (7)", scores)
+	}
+}
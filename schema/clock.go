@@ -0,0 +1,65 @@
+package schema
+
+import "time"
+
+// clockSamples は ClockSync が保持する直近のサンプル数。
+const clockSamples = 8
+
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// ClockSync は Ping/Pong の往復から相手の時計とのずれを推定する。
+// 直近のサンプルのうち往復時間が最も短いものを採用する。
+// ゼロ値はサンプルなしの状態で、ずれ 0 とみなす。
+type ClockSync struct {
+	samples [clockSamples]clockSample
+	n, next int
+}
+
+// Add は sent に送った Ping に相手が remote の時刻で応答し、
+// received に受け取ったというサンプルを追加する。
+func (c *ClockSync) Add(sent, remote, received time.Time) {
+	rtt := received.Sub(sent)
+	if rtt < 0 {
+		return
+	}
+	c.samples[c.next] = clockSample{
+		offset: remote.Sub(sent.Add(rtt / 2)),
+		rtt:    rtt,
+	}
+	c.next = (c.next + 1) % clockSamples
+	c.n = min(c.n+1, clockSamples)
+}
+
+func (c *ClockSync) best() (clockSample, bool) {
+	if c.n == 0 {
+		return clockSample{}, false
+	}
+	best := c.samples[0]
+	for _, s := range c.samples[1:c.n] {
+		if s.rtt < best.rtt {
+			best = s
+		}
+	}
+	return best, true
+}
+
+// Offset は相手の時計がこちらより進んでいる量。サンプルがなければ ok は false。
+func (c *ClockSync) Offset() (offset time.Duration, ok bool) {
+	s, ok := c.best()
+	return s.offset, ok
+}
+
+// RTT は採用したサンプルの往復時間。
+func (c *ClockSync) RTT() time.Duration {
+	s, _ := c.best()
+	return s.rtt
+}
+
+// Local は相手の時計の時刻 remote をこちらの時計に換算する。
+func (c *ClockSync) Local(remote time.Time) time.Time {
+	offset, _ := c.Offset()
+	return remote.Add(-offset)
+}
@@ -3,11 +3,13 @@ package schema
 import "math"
 
 type Info struct {
-	ID   string  `json:"id"`
-	Name string  `json:"name"`
-	X    float64 `json:"x"`
-	Y    float64 `json:"y"`
-	Fire bool    `json:"fire"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Device はスコープ端末ごとに localStorage に保存される不変の識別子。
+	Device string  `json:"device,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Fire   bool    `json:"fire"`
 }
 
 type Point struct {
@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// コーデック名。スコープは Hello で使うコーデックを宣言し、
+// ホストはそれ以降そのコーデックで照準の更新を受け付ける。
+const (
+	CodecJSON   = "json"
+	CodecBinary = "binary"
+)
+
+const (
+	// FrameVersion は Frame のバイナリ形式のバージョン。
+	FrameVersion = 1
+	// FrameSize は Frame をエンコードしたときのバイト数。
+	FrameSize = 10
+
+	// 量子化する座標の範囲。画面外を狙った場合も表現できるよう 0-1 より広く取る。
+	frameMin = -1.0
+	frameMax = 2.0
+)
+
+var ErrFrameSize = errors.New("schema: invalid frame size")
+
+// FrameFlags は将来の拡張用に予約している。射撃は Frame ではなく
+// ChannelShot で Fire として送る。
+type FrameFlags uint8
+
+// Frame は高頻度で送る照準更新の固定長バイナリ表現。
+// 送信者の識別は Hello で一度だけ行い、Frame には含めない。
+//
+//	offset size
+//	0      1    version
+//	1      1    flags
+//	2      4    seq (big endian)
+//	6      2    x (量子化, big endian)
+//	8      2    y (量子化, big endian)
+type Frame struct {
+	Seq   uint32
+	X, Y  float64
+	Flags FrameFlags
+}
+
+// Message は Frame を対応する Aim に変換する。
+func (f Frame) Message() Message {
+	return Aim{X: f.X, Y: f.Y}
+}
+
+func (f Frame) MarshalBinary() ([]byte, error) {
+	return f.AppendBinary(make([]byte, 0, FrameSize))
+}
+
+func (f Frame) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, FrameVersion, byte(f.Flags))
+	b = binary.BigEndian.AppendUint32(b, f.Seq)
+	b = binary.BigEndian.AppendUint16(b, quantize(f.X))
+	b = binary.BigEndian.AppendUint16(b, quantize(f.Y))
+	return b, nil
+}
+
+func (f *Frame) UnmarshalBinary(b []byte) error {
+	if len(b) != FrameSize {
+		return fmt.Errorf("%w: %d", ErrFrameSize, len(b))
+	}
+	if b[0] != FrameVersion {
+		return fmt.Errorf("%w: frame %d", ErrUnsupportedVersion, b[0])
+	}
+	f.Flags = FrameFlags(b[1])
+	f.Seq = binary.BigEndian.Uint32(b[2:6])
+	f.X = dequantize(binary.BigEndian.Uint16(b[6:8]))
+	f.Y = dequantize(binary.BigEndian.Uint16(b[8:10]))
+	return nil
+}
+
+func quantize(v float64) uint16 {
+	if math.IsNaN(v) {
+		v = 0.5
+	}
+	t := (v - frameMin) / (frameMax - frameMin)
+	t = min(max(t, 0), 1)
+	return uint16(math.Round(t * math.MaxUint16))
+}
+
+func dequantize(q uint16) float64 {
+	return frameMin + float64(q)/math.MaxUint16*(frameMax-frameMin)
+}
@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// frameStep は量子化の 1 段階の幅。誤差はその半分 (maxFrameError) 以内に収まる。
+const (
+	frameStep     = (frameMax - frameMin) / math.MaxUint16
+	maxFrameError = frameStep/2 + 1e-12
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	for i := 0; i <= 3000; i++ {
+		v := frameMin + float64(i)*(frameMax-frameMin)/3000
+		in := Frame{Seq: uint32(i) * 7919, X: v, Y: frameMax + frameMin - v, Flags: FrameFlags(i)}
+		b, err := in.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary: %v", err)
+		}
+		if len(b) != FrameSize {
+			t.Fatalf("len = %d, want %d", len(b), FrameSize)
+		}
+		var out Frame
+		if err := out.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary: %v", err)
+		}
+		if out.Seq != in.Seq || out.Flags != in.Flags {
+			t.Errorf("seq/flags = %d/%d, want %d/%d", out.Seq, out.Flags, in.Seq, in.Flags)
+		}
+		if math.Abs(out.X-in.X) > maxFrameError || math.Abs(out.Y-in.Y) > maxFrameError {
+			t.Errorf("(%v, %v) decoded as (%v, %v), error exceeds %v", in.X, in.Y, out.X, out.Y, maxFrameError)
+		}
+	}
+}
+
+func TestFrameClamp(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{in: -5, want: frameMin},
+		{in: math.Inf(-1), want: frameMin},
+		{in: 10, want: frameMax},
+		{in: math.Inf(1), want: frameMax},
+		{in: math.NaN(), want: 0.5},
+	}
+	for _, tt := range tests {
+		b, _ := Frame{X: tt.in, Y: tt.in}.MarshalBinary()
+		var out Frame
+		if err := out.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary(%v): %v", tt.in, err)
+		}
+		if math.Abs(out.X-tt.want) > maxFrameError || math.Abs(out.Y-tt.want) > maxFrameError {
+			t.Errorf("%v decoded as (%v, %v), want %v", tt.in, out.X, out.Y, tt.want)
+		}
+	}
+}
+
+func TestFrameRejectsInvalid(t *testing.T) {
+	valid, _ := Frame{Seq: 1, X: 0.5, Y: 0.5}.MarshalBinary()
+	wrongVersion := append([]byte(nil), valid...)
+	wrongVersion[0] = FrameVersion + 1
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "empty", data: nil, want: ErrFrameSize},
+		{name: "short", data: valid[:FrameSize-1], want: ErrFrameSize},
+		{name: "long", data: append(append([]byte(nil), valid...), 0), want: ErrFrameSize},
+		{name: "version", data: wrongVersion, want: ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		var f Frame
+		if err := f.UnmarshalBinary(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func FuzzUnmarshalFrame(f *testing.F) {
+	valid, _ := Frame{Seq: 42, X: 0.25, Y: 0.75}.MarshalBinary()
+	f.Add(valid)
+	f.Add([]byte{})
+	f.Add([]byte{FrameVersion})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var frame Frame
+		if err := frame.UnmarshalBinary(data); err != nil {
+			return
+		}
+		if frame.X < frameMin || frame.X > frameMax || frame.Y < frameMin || frame.Y > frameMax {
+			t.Errorf("decoded (%v, %v) out of range", frame.X, frame.Y)
+		}
+		// 受け付けたフレームはエンコードし直すと同じバイト列になる
+		b, err := frame.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary: %v", err)
+		}
+		if string(b) != string(data) {
+			t.Errorf("re-encoded %x, want %x", b, data)
+		}
+	})
+}
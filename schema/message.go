@@ -0,0 +1,303 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ProtocolVersion はスコープとホストの間のメッセージ形式のバージョン。
+// 互換性のない変更を加えたら上げること。
+const ProtocolVersion = 1
+
+var (
+	ErrUnsupportedVersion = errors.New("schema: unsupported protocol version")
+	ErrUnknownType        = errors.New("schema: unknown message type")
+)
+
+// スコープが接続後に開くデータチャネルのラベル。
+// 照準は失われてもよいので順序保証も再送もなし、射撃は信頼性のある順序付きチャネルで送る。
+// Hello などの制御メッセージは接続時のデフォルトチャネルを使う。
+const (
+	ChannelAim  = "aim"
+	ChannelShot = "shot"
+)
+
+type MessageType string
+
+const (
+	TypeHello     MessageType = "hello"
+	TypeAim       MessageType = "aim"
+	TypeFire      MessageType = "fire"
+	TypeHeartbeat MessageType = "heartbeat"
+	TypeLeave     MessageType = "leave"
+	TypeReady     MessageType = "ready"
+	TypePing      MessageType = "ping"
+	TypePong      MessageType = "pong"
+
+	TypeShotResult MessageType = "shot_result"
+	TypeStatus     MessageType = "status"
+	TypeDenied     MessageType = "denied"
+	TypeWelcome    MessageType = "welcome"
+	TypeFeed       MessageType = "feed"
+	TypeSimState   MessageType = "sim_state"
+)
+
+// DeviceSimulator は cmd/scopesim が Hello.Device に使う識別子。
+// スコープの自己申告なので、ホストはこれを見て特別扱いはしない。
+const DeviceSimulator = "scopesim"
+
+// Hello.Role の値。空は RolePlayer として扱う。
+const (
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+)
+
+// Status.Mode の値。rules.Mode の String と同じ。
+const (
+	ModeWaiting     = "waiting"
+	ModeCalibration = "calibration"
+	ModeCountdown   = "countdown"
+	ModePlaying     = "playing"
+	ModeGameOver    = "gameover"
+)
+
+// Envelope はデータチャネルを流れる全メッセージの共通ヘッダ。
+type Envelope struct {
+	Type    MessageType     `json:"type"`
+	Version int             `json:"v"`
+	Seq     uint32          `json:"seq"`
+	Time    int64           `json:"ts"` // 送信側の時刻 (Unix ミリ秒)
+	Payload json.RawMessage `json:"data,omitempty"`
+}
+
+// Timestamp は送信側の時刻を time.Time で返す。
+func (e Envelope) Timestamp() time.Time {
+	return time.UnixMilli(e.Time)
+}
+
+// Message は Envelope に格納できるメッセージ。
+type Message interface {
+	MessageType() MessageType
+}
+
+// Hello は接続直後にスコープが名乗るためのメッセージ。
+type Hello struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Device string `json:"device,omitempty"`
+	Codec  string `json:"codec,omitempty"` // 照準の更新に使うコーデック。空なら CodecJSON
+	PIN    string `json:"pin,omitempty"`   // ルームに PIN が設定されている場合に必要
+	Token  string `json:"token,omitempty"` // 再接続時に前回の Welcome で受け取ったトークン
+	Role   string `json:"role,omitempty"`  // RoleSpectator なら観戦のみで、入力は受け付けられない
+}
+
+// Aim は照準の生マーカー座標。
+type Aim struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Fire は射撃。撃った瞬間の照準と時刻を含む。
+// ShotID はスコープごとに 1 から単調増加し、ホストは同じ ID を二度数えない。
+type Fire struct {
+	ShotID uint32  `json:"shot"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Time   int64   `json:"t"` // 引き金を引いたスコープの時刻 (Unix ミリ秒)
+}
+
+// Heartbeat は照準の更新がなくても接続が生きていることを伝える。
+type Heartbeat struct{}
+
+// Leave はスコープが自発的に退出することを伝える。
+type Leave struct{}
+
+// Ready はスコープのプレイヤーが準備できたことを伝える。
+// 参加中の全員が Ready を送るとキャリブレーションが始まる。
+type Ready struct{}
+
+// Ping は時計のずれを測るためにホストがスコープへ送る。
+type Ping struct {
+	Time int64 `json:"t"` // ホストの送信時刻 (Unix ミリ秒)
+}
+
+// Pong は Ping への応答。
+type Pong struct {
+	Ping int64 `json:"ping"` // 受け取った Ping の Time
+	Time int64 `json:"t"`    // スコープの応答時刻 (Unix ミリ秒)
+}
+
+// ShotResult はホストが判定した射撃の結果。
+// キャリブレーション中は受け付けられたかどうかを Hit で表す。
+type ShotResult struct {
+	ShotID   uint32 `json:"shot"`
+	Hit      bool   `json:"hit"`
+	Points   int    `json:"points,omitempty"`
+	Bullseye bool   `json:"bullseye,omitempty"`
+	// Destroyed はこの命中でターゲットが壊れたかどうか。
+	// 装甲が残っているターゲットへの命中は Hit でも false で、得点は入らない。
+	Destroyed bool `json:"destroyed,omitempty"`
+	// Combo は Points に掛かったコンボの倍率。倍率が掛かっていなければ 0。
+	Combo int `json:"combo,omitempty"`
+}
+
+// Denied はホストが参加を断ったことを伝える。送信後にホストは接続を切る。
+type Denied struct {
+	Reason string `json:"reason"`
+}
+
+// Welcome はホストが参加を受け付けたことを伝える。
+// 接続が切れたら同じ ID で接続し直し、Hello に Token を付けると前回の続きから参加できる。
+type Welcome struct {
+	Token   string `json:"token"`
+	Resumed bool   `json:"resumed,omitempty"` // 前回の続きとして受け付けたかどうか
+}
+
+// Status はゲームの進行状況と受信者の成績。ホストから定期的に送る。
+// 座標はいずれもキャリブレーション済みの正規化座標 (0-1)。
+type Status struct {
+	Mode             string `json:"mode"`
+	Ready            bool   `json:"ready,omitempty"` // 受信者が Ready を送ったかどうか
+	CalibrationIndex int    `json:"calib"`
+	CalibrationCount int    `json:"calibCount"`
+	Remaining        int64  `json:"remaining"` // 残り時間 (ミリ秒)
+	Score            int    `json:"score"`
+	Rank             int    `json:"rank"`
+	Players          int    `json:"players"`
+}
+
+// SimState はシミュレーターが狙いを付けるための情報。Status と一緒に送る。
+// 照準の補助になるので、ホストが debug パラメーター付きで起動したときにしか送らない。
+// 座標はいずれもキャリブレーション済みの正規化座標 (0-1)。
+type SimState struct {
+	CalibrationTarget Point   `json:"calibTarget"` // 現在撃つべきキャリブレーションターゲット
+	Targets           []Point `json:"targets,omitempty"`
+}
+
+// Feed は観戦クライアントに送る試合の様子。ホストから定期的に送る。
+// 座標はいずれもキャリブレーション済みの正規化座標 (0-1)。
+// ホスト画面のピクセル座標は Inset + 座標 * (Width - 2*Inset) で求める。
+type Feed struct {
+	Mode      string `json:"mode"`
+	Remaining int64  `json:"remaining"` // 残り時間 (ミリ秒)
+	// Width, Height はホスト画面のピクセルサイズ。Inset は正規化座標の 0 が画面の端からどれだけ内側か。
+	Width   float64      `json:"width"`
+	Height  float64      `json:"height"`
+	Inset   float64      `json:"inset"`
+	Targets []FeedTarget `json:"targets,omitempty"`
+	Players []FeedPlayer `json:"players,omitempty"` // 順位順
+}
+
+// FeedTarget は Feed に含める 1 つのターゲット。半径はホスト画面のピクセル単位。
+type FeedTarget struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Radius   float64 `json:"r"`
+	Bullseye float64 `json:"bullseye"`       // 中心の半径
+	Kind     string  `json:"kind,omitempty"` // rules.TargetKind の String
+}
+
+// FeedPlayer は Feed に含める 1 人分の状態。
+type FeedPlayer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+	Rank   int    `json:"rank"`
+	Cursor Point  `json:"cursor"`
+	Active bool   `json:"active"`
+	Team   int    `json:"team,omitempty"` // チーム戦の所属チーム。0 なら個人戦
+}
+
+func (Hello) MessageType() MessageType      { return TypeHello }
+func (Aim) MessageType() MessageType        { return TypeAim }
+func (Fire) MessageType() MessageType       { return TypeFire }
+func (Heartbeat) MessageType() MessageType  { return TypeHeartbeat }
+func (Leave) MessageType() MessageType      { return TypeLeave }
+func (Ready) MessageType() MessageType      { return TypeReady }
+func (Ping) MessageType() MessageType       { return TypePing }
+func (Pong) MessageType() MessageType       { return TypePong }
+func (ShotResult) MessageType() MessageType { return TypeShotResult }
+func (Status) MessageType() MessageType     { return TypeStatus }
+func (Denied) MessageType() MessageType     { return TypeDenied }
+func (Welcome) MessageType() MessageType    { return TypeWelcome }
+func (Feed) MessageType() MessageType       { return TypeFeed }
+func (SimState) MessageType() MessageType   { return TypeSimState }
+
+// Encode は msg を Envelope に包んで JSON にする。
+func Encode(msg Message, seq uint32, t time.Time) ([]byte, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", msg.MessageType(), err)
+	}
+	return json.Marshal(Envelope{
+		Type:    msg.MessageType(),
+		Version: ProtocolVersion,
+		Seq:     seq,
+		Time:    t.UnixMilli(),
+		Payload: payload,
+	})
+}
+
+// Decode は Encode で作られたデータを Envelope とメッセージに戻す。
+// 受信側より新しいバージョンのメッセージは ErrUnsupportedVersion を返す。
+func Decode(data []byte) (Envelope, Message, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+	if env.Version < 1 || env.Version > ProtocolVersion {
+		return env, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, env.Version)
+	}
+	var (
+		msg Message
+		err error
+	)
+	switch env.Type {
+	case TypeHello:
+		msg, err = decodePayload[Hello](env.Payload)
+	case TypeAim:
+		msg, err = decodePayload[Aim](env.Payload)
+	case TypeFire:
+		msg, err = decodePayload[Fire](env.Payload)
+	case TypeHeartbeat:
+		msg, err = decodePayload[Heartbeat](env.Payload)
+	case TypeLeave:
+		msg, err = decodePayload[Leave](env.Payload)
+	case TypeReady:
+		msg, err = decodePayload[Ready](env.Payload)
+	case TypePing:
+		msg, err = decodePayload[Ping](env.Payload)
+	case TypePong:
+		msg, err = decodePayload[Pong](env.Payload)
+	case TypeShotResult:
+		msg, err = decodePayload[ShotResult](env.Payload)
+	case TypeStatus:
+		msg, err = decodePayload[Status](env.Payload)
+	case TypeDenied:
+		msg, err = decodePayload[Denied](env.Payload)
+	case TypeWelcome:
+		msg, err = decodePayload[Welcome](env.Payload)
+	case TypeFeed:
+		msg, err = decodePayload[Feed](env.Payload)
+	case TypeSimState:
+		msg, err = decodePayload[SimState](env.Payload)
+	default:
+		return env, nil, fmt.Errorf("%w: %q", ErrUnknownType, env.Type)
+	}
+	if err != nil {
+		return env, nil, fmt.Errorf("failed to unmarshal %s: %w", env.Type, err)
+	}
+	return env, msg, nil
+}
+
+func decodePayload[T Message](payload json.RawMessage) (Message, error) {
+	var msg T
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
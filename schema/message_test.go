@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	messages := []Message{
+		Hello{ID: "p1", Name: "Alice", Device: "dev", Codec: CodecBinary, PIN: "1234", Token: "tok", Role: RoleSpectator},
+		Aim{X: 0.25, Y: 0.75},
+		Fire{ShotID: 3, X: 0.5, Y: 0.5, Time: 1700000000123},
+		Heartbeat{},
+		Leave{},
+		Ready{},
+		Ping{Time: 1700000000000},
+		Pong{Ping: 1700000000000, Time: 1700000000050},
+		ShotResult{ShotID: 3, Hit: true, Points: 10, Bullseye: true, Destroyed: true, Combo: 2},
+		Status{
+			Mode:             ModePlaying,
+			Ready:            true,
+			CalibrationIndex: 2,
+			CalibrationCount: 4,
+			Remaining:        30000,
+			Score:            12,
+			Rank:             1,
+			Players:          3,
+		},
+		Denied{Reason: "full"},
+		Welcome{Token: "tok", Resumed: true},
+		Feed{
+			Mode:      ModePlaying,
+			Remaining: 1000,
+			Width:     1920,
+			Height:    1080,
+			Inset:     100,
+			Targets:   []FeedTarget{{X: 0.5, Y: 0.5, Radius: 60, Bullseye: 30, Kind: "bonus"}},
+			Players:   []FeedPlayer{{ID: "p1", Name: "Alice", Score: 3, Rank: 1, Cursor: Point{X: 0.4, Y: 0.6}, Active: true, Team: 2}},
+		},
+		SimState{CalibrationTarget: Point{X: 0.75, Y: 0.25}, Targets: []Point{{X: 0.1, Y: 0.2}}},
+	}
+	now := time.UnixMilli(1700000000123)
+	for i, msg := range messages {
+		data, err := Encode(msg, uint32(i), now)
+		if err != nil {
+			t.Fatalf("Encode(%T): %v", msg, err)
+		}
+		env, got, err := Decode(data)
+		if err != nil {
+			t.Fatalf("Decode(%T): %v", msg, err)
+		}
+		if env.Type != msg.MessageType() || env.Version != ProtocolVersion || env.Seq != uint32(i) {
+			t.Errorf("%T: envelope = %+v", msg, env)
+		}
+		if !env.Timestamp().Equal(now) {
+			t.Errorf("%T: timestamp = %v, want %v", msg, env.Timestamp(), now)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Errorf("%T: decoded %#v, want %#v", msg, got, msg)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	envelope := func(env Envelope) []byte {
+		data, err := json.Marshal(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "newer version", data: envelope(Envelope{Type: TypeAim, Version: ProtocolVersion + 1}), want: ErrUnsupportedVersion},
+		{name: "missing version", data: envelope(Envelope{Type: TypeAim}), want: ErrUnsupportedVersion},
+		{name: "unknown type", data: envelope(Envelope{Type: "teleport", Version: ProtocolVersion}), want: ErrUnknownType},
+	}
+	for _, tt := range tests {
+		if _, _, err := Decode(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, _, err := Decode([]byte("{")); err == nil {
+		t.Error("Decode accepted malformed JSON")
+	}
+	bad := envelope(Envelope{Type: TypeAim, Version: ProtocolVersion, Payload: json.RawMessage(`"x"`)})
+	if _, _, err := Decode(bad); err == nil {
+		t.Error("Decode accepted a malformed payload")
+	}
+}
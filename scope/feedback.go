@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math/rand"
+	"syscall/js"
+	"time"
+
+	"github.com/nobonobo/gun-shooter/schema"
+)
+
+// 射撃結果ごとの navigator.vibrate のパターン (ミリ秒)。
+var (
+	vibrateMiss     = []any{30}
+	vibrateHit      = []any{80}
+	vibrateBullseye = []any{60, 40, 150}
+	vibrateCalib    = []any{40}
+	vibratePenalty  = []any{200, 60, 200}
+	vibrateArmor    = []any{20, 30, 20}
+)
+
+// Feedback は射撃とその結果を振動・音・ヒットマーカーで手元に返す。
+// URL パラメータ vibrate=false, sound=true, hitmarker=false で切り替える。
+type Feedback struct {
+	vibrate   bool
+	sound     bool
+	hitmarker bool
+
+	audioCtx  js.Value
+	noise     js.Value // 射撃音のホワイトノイズ
+	marker    js.Value
+	markerEnd *time.Timer
+}
+
+func NewFeedback() *Feedback {
+	return &Feedback{
+		vibrate:   GetParam("vibrate") != "false" && !navigator.Get("vibrate").IsUndefined(),
+		sound:     GetParam("sound") == "true",
+		hitmarker: GetParam("hitmarker") != "false",
+		marker:    document.Call("getElementById", "hitmarker"),
+	}
+}
+
+// Shot は引き金を引いた瞬間に呼ぶ。
+// AudioContext はユーザー操作の中でしか開始できないので、初回の射撃で作る。
+func (f *Feedback) Shot() {
+	if !f.sound {
+		return
+	}
+	if f.audioCtx.IsUndefined() {
+		ctor := js.Global().Get("AudioContext")
+		if ctor.IsUndefined() {
+			ctor = js.Global().Get("webkitAudioContext")
+		}
+		if ctor.IsUndefined() {
+			f.sound = false
+			return
+		}
+		f.audioCtx = ctor.New()
+		f.noise = f.createNoise(0.15)
+	}
+	// 減衰するノイズで銃声を合成する
+	ctx := f.audioCtx
+	now := ctx.Get("currentTime").Float()
+	src := ctx.Call("createBufferSource")
+	src.Set("buffer", f.noise)
+	gain := ctx.Call("createGain")
+	gain.Get("gain").Call("setValueAtTime", 0.8, now)
+	gain.Get("gain").Call("exponentialRampToValueAtTime", 0.01, now+0.15)
+	src.Call("connect", gain)
+	gain.Call("connect", ctx.Get("destination"))
+	src.Call("start", now)
+}
+
+func (f *Feedback) createNoise(seconds float64) js.Value {
+	rate := f.audioCtx.Get("sampleRate").Float()
+	n := int(rate * seconds)
+	buf := f.audioCtx.Call("createBuffer", 1, n, rate)
+	data := buf.Call("getChannelData", 0)
+	for i := 0; i < n; i++ {
+		data.SetIndex(i, rand.Float64()*2-1)
+	}
+	return buf
+}
+
+// Result はホストから届いた射撃結果を返す。
+func (f *Feedback) Result(result schema.ShotResult, calibrating bool) {
+	var pattern []any
+	switch {
+	case calibrating:
+		if result.Hit {
+			pattern = vibrateCalib
+		}
+	case result.Points < 0:
+		pattern = vibratePenalty
+		f.showMarker("penalty")
+	case result.Hit && !result.Destroyed:
+		pattern = vibrateArmor
+		f.showMarker("armor")
+	case result.Bullseye:
+		pattern = vibrateBullseye
+		f.showMarker("bullseye")
+	case result.Hit:
+		pattern = vibrateHit
+		f.showMarker("hit")
+	default:
+		pattern = vibrateMiss
+	}
+	if f.vibrate && pattern != nil {
+		navigator.Call("vibrate", pattern)
+	}
+}
+
+func (f *Feedback) showMarker(class string) {
+	if !f.hitmarker || f.marker.IsNull() {
+		return
+	}
+	f.marker.Set("className", "show "+class)
+	if f.markerEnd != nil {
+		f.markerEnd.Stop()
+	}
+	f.markerEnd = time.AfterFunc(200*time.Millisecond, func() {
+		f.marker.Set("className", "")
+	})
+}
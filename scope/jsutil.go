@@ -3,16 +3,20 @@ package main
 import (
 	"net/url"
 	"syscall/js"
+
+	"github.com/google/uuid"
 )
 
 var (
-	document = js.Global().Get("document")
-	window   = js.Global().Get("window")
-	location = js.Global().Get("location")
-	console  = js.Global().Get("console")
-	THREE    = js.Global().Get("THREE")
-	THREEx   = js.Global().Get("THREEx")
-	params   url.Values
+	document  = js.Global().Get("document")
+	window    = js.Global().Get("window")
+	location  = js.Global().Get("location")
+	navigator = js.Global().Get("navigator")
+	console   = js.Global().Get("console")
+	storage   = js.Global().Get("localStorage")
+	THREE     = js.Global().Get("THREE")
+	THREEx    = js.Global().Get("THREEx")
+	params    url.Values
 )
 
 func init() {
@@ -29,6 +33,19 @@ func SetParam(key, value string) {
 	location.Set("search", params.Encode())
 }
 
+// deviceKey は端末識別子を保存する localStorage のキー。
+const deviceKey = "gun-shooter.device"
+
+// DeviceID はこの端末に固有の識別子を返す。初回は生成して localStorage に保存する。
+func DeviceID() string {
+	if v := storage.Call("getItem", deviceKey); !v.IsNull() && v.String() != "" {
+		return v.String()
+	}
+	uid, _ := uuid.NewV6()
+	storage.Call("setItem", deviceKey, uid.String())
+	return uid.String()
+}
+
 type goObject struct {
 	jsValue js.Value
 }
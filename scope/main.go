@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"math"
@@ -14,6 +13,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/nobonobo/gun-shooter/schema"
+	"github.com/nobonobo/gun-shooter/signaling"
 	"github.com/nobonobo/rtcconnect/node"
 	"github.com/pion/webrtc/v4"
 )
@@ -27,13 +27,29 @@ type Application struct {
 	markers      []js.Value
 	patternUrls  []string
 	uid          string
+	device       string
 	name         string
 	dest         string
-	node         *node.Node
+	signal       string // 空なら rtcconnect の公開サービスを使う
+	node         signaling.Transport
+	token        string // 再接続で前回の続きから参加するためのトークン
+	reconnecting bool
+	denied       bool // ホストに断られたら再接続しない
 	ctx          context.Context
 	cancel       context.CancelFunc
 	cnt          int
+	seq          uint32
+	codec        string
+	aimDC        *webrtc.DataChannel
+	shotDC       *webrtc.DataChannel
+	shotID       uint32
+	shots        []schema.Fire // ホストへの送信待ちの射撃
 	fire         bool
+	fireAt       time.Time // 引き金を引いた時刻
+	status       *schema.Status
+	result       string    // 直前の射撃結果の表示
+	resultUntil  time.Time // result を表示し続ける期限
+	feedback     *Feedback
 	flip         bool
 	OnUpdate     func([4]Marker)
 }
@@ -47,7 +63,11 @@ func NewApplication() *Application {
 	}
 	dest := u.Query().Get("dest")
 	flip := u.Query().Get("flip") == "true"
-	n := node.New(uid.String())
+	// デバッグ時は codec=json で照準も JSON で送れる
+	codec := u.Query().Get("codec")
+	if codec != schema.CodecJSON {
+		codec = schema.CodecBinary
+	}
 	app := &Application{
 		patternUrls: []string{
 			"marker/pattern-marker_0.patt",
@@ -56,30 +76,215 @@ func NewApplication() *Application {
 			"marker/pattern-marker_2.patt",
 		},
 		uid:      uid.String(),
+		device:   DeviceID(),
 		name:     name,
 		dest:     dest,
-		node:     n,
+		signal:   u.Query().Get("signal"),
 		ctx:      context.Background(),
 		cancel:   func() {},
 		flip:     flip,
+		codec:    codec,
+		feedback: NewFeedback(),
 		OnUpdate: func(markers [4]Marker) {},
 	}
+	app.node = app.newNode()
 	return app
 }
 
-func (app *Application) Publish(data []byte, force bool) error {
+// newNode は同じ ID で接続する新しいノードを作る。
+// signal が指定されていればローカルのシグナリングサーバーを使う。
+func (app *Application) newNode() signaling.Transport {
+	if app.signal != "" {
+		return signaling.New(app.signal, app.uid)
+	}
+	return node.New(app.uid)
+}
+
+func (app *Application) Publish(dc *webrtc.DataChannel, data []byte, binary, force bool) error {
 	app.cnt++
 	if app.cnt%100 == 0 {
-		fmt.Println("publish:", string(data))
+		if binary {
+			fmt.Printf("publish: %x\n", data)
+		} else {
+			fmt.Println("publish:", string(data))
+		}
+	}
+	if dc == nil {
+		return fmt.Errorf("data channel not created")
 	}
-	dc := app.node.DataChannel()
 	if dc.ReadyState() != webrtc.DataChannelStateOpen {
 		return fmt.Errorf("data channel not open: %s", dc.ReadyState())
 	}
 	if dc.BufferedAmount() > 128 && !force {
 		return fmt.Errorf("buffer full")
 	}
-	return dc.Send(data)
+	if binary {
+		return dc.Send(data)
+	}
+	return dc.SendText(string(data))
+}
+
+// Send は msg を種類に応じたチャネルで送信する。
+// 照準は ChannelAim に (バイナリコーデックでは Frame で)、射撃は ChannelShot に、
+// それ以外はデフォルトチャネルに Envelope に包んだ JSON で送る。
+func (app *Application) Send(msg schema.Message, force bool) error {
+	app.seq++
+	dc := app.node.DataChannel()
+	switch m := msg.(type) {
+	case schema.Aim:
+		dc = app.aimDC
+		if app.codec == schema.CodecBinary {
+			b, err := schema.Frame{Seq: app.seq, X: m.X, Y: m.Y}.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			return app.Publish(dc, b, true, force)
+		}
+	case schema.Fire:
+		// 射撃は信頼性のあるチャネルなのでバッファが詰まっていても捨てない
+		dc = app.shotDC
+		force = true
+	}
+	b, err := schema.Encode(msg, app.seq, time.Now())
+	if err != nil {
+		return err
+	}
+	return app.Publish(dc, b, false, force)
+}
+
+// Shoot は引き金を引いた時刻 at と照準で射撃を送信待ちに積み、送れるだけ送る。
+// 送れなかった射撃は同じ ShotID のまま次回に再送する。
+func (app *Application) Shoot(x, y float64, at time.Time) {
+	app.shotID++
+	app.shots = append(app.shots, schema.Fire{
+		ShotID: app.shotID,
+		X:      x,
+		Y:      y,
+		Time:   at.UnixMilli(),
+	})
+	app.flushShots()
+}
+
+func (app *Application) flushShots() {
+	for len(app.shots) > 0 {
+		if err := app.Send(app.shots[0], true); err != nil {
+			return
+		}
+		app.shots = app.shots[1:]
+	}
+}
+
+// openChannels は照準用と射撃用のデータチャネルを開く。
+func (app *Application) openChannels() error {
+	pc := app.node.PeerConnection()
+	ordered := false
+	maxRetransmits := uint16(0)
+	aim, err := pc.CreateDataChannel(schema.ChannelAim, &webrtc.DataChannelInit{
+		Ordered:        &ordered,
+		MaxRetransmits: &maxRetransmits,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s channel: %w", schema.ChannelAim, err)
+	}
+	shot, err := pc.CreateDataChannel(schema.ChannelShot, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s channel: %w", schema.ChannelShot, err)
+	}
+	shot.OnOpen(app.flushShots)
+	app.aimDC, app.shotDC = aim, shot
+	return nil
+}
+
+// onMessage はホストから届いたメッセージを処理する。
+func (app *Application) onMessage(msg webrtc.DataChannelMessage) {
+	_, m, err := schema.Decode(msg.Data)
+	if err != nil {
+		log.Println("failed to decode message:", err)
+		return
+	}
+	switch m := m.(type) {
+	case schema.Ping:
+		app.Send(schema.Pong{Ping: m.Time, Time: time.Now().UnixMilli()}, true)
+	case schema.ShotResult:
+		app.onShotResult(m)
+	case schema.Status:
+		app.status = &m
+	case schema.Welcome:
+		log.Println("welcome: resumed =", m.Resumed)
+		app.token = m.Token
+	case schema.Denied:
+		log.Println("denied:", m.Reason)
+		app.denied = true
+		showOverlay("参加を拒否されました: " + m.Reason)
+	}
+}
+
+func (app *Application) onShotResult(result schema.ShotResult) {
+	calibrating := app.status != nil && app.status.Mode == schema.ModeCalibration
+	app.feedback.Result(result, calibrating)
+	switch {
+	case calibrating:
+		if result.Hit {
+			app.result = "OK"
+		} else {
+			app.result = "WAIT"
+		}
+	case result.Points < 0:
+		// 撃ってはいけないターゲットに当てた
+		app.result = fmt.Sprintf("PENALTY %d", result.Points)
+	case result.Hit && !result.Destroyed:
+		// 装甲に弾かれた。壊すまで得点は入らない
+		app.result = "ARMOR"
+	case result.Bullseye:
+		app.result = fmt.Sprintf("BULLSEYE! +%d", result.Points)
+	case result.Hit:
+		app.result = fmt.Sprintf("HIT +%d", result.Points)
+	default:
+		app.result = "MISS"
+	}
+	if result.Combo > 1 && result.Points > 0 {
+		app.result += fmt.Sprintf(" x%d", result.Combo)
+	}
+	app.resultUntil = time.Now().Add(time.Second)
+}
+
+// waiting はホストが参加者の準備を待っているかどうか。この間のタップは Ready になる。
+func (app *Application) waiting() bool {
+	return app.status != nil && app.status.Mode == schema.ModeWaiting
+}
+
+// Message はメッセージボックスに表示する文字列を返す。
+// ホストから Status が届く前は照準の座標を表示する。
+func (app *Application) Message(x, y float64) string {
+	if app.reconnecting {
+		return "RECONNECTING..."
+	}
+	if app.status == nil {
+		return fmt.Sprintf("x:%5.2f, y:%5.2f", x, y)
+	}
+	s := app.status
+	var line string
+	switch s.Mode {
+	case schema.ModeWaiting:
+		if s.Ready {
+			line = "READY  WAITING FOR OTHERS"
+		} else {
+			line = "TAP TO READY"
+		}
+	case schema.ModeCalibration:
+		line = fmt.Sprintf("CALIBRATION %d/%d", min(s.CalibrationIndex+1, s.CalibrationCount), s.CalibrationCount)
+	case schema.ModeCountdown:
+		line = fmt.Sprintf("READY %d", (s.Remaining+999)/1000)
+	case schema.ModePlaying:
+		sec := (s.Remaining + 999) / 1000
+		line = fmt.Sprintf("SCORE %d  RANK %d/%d  %d:%02d", s.Score, s.Rank, s.Players, sec/60, sec%60)
+	case schema.ModeGameOver:
+		line = fmt.Sprintf("GAME OVER  SCORE %d  RANK %d/%d", s.Score, s.Rank, s.Players)
+	}
+	if app.result != "" && time.Now().Before(app.resultUntil) {
+		line += "\n" + app.result
+	}
+	return line
 }
 
 func (app *Application) Connect(ctx context.Context) error {
@@ -87,11 +292,82 @@ func (app *Application) Connect(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := app.openChannels(); err != nil {
+		return err
+	}
+	app.node.DataChannel().OnMessage(app.onMessage)
+	return app.Send(schema.Hello{
+		ID:     app.uid,
+		Name:   app.name,
+		Device: app.device,
+		Codec:  app.codec,
+		PIN:    GetParam("pin"),
+		Token:  app.token,
+	}, true)
+}
+
+const (
+	// reconnectTimeout を過ぎても再接続できなければ諦めてオーバーレイを出す。
+	// ホストがプレイヤーを残しておく DisconnectRetention より短くする。
+	reconnectTimeout  = 45 * time.Second
+	reconnectMinDelay = 500 * time.Millisecond
+	reconnectMaxDelay = 8 * time.Second
+	connectTimeout    = 15 * time.Second
+)
+
+// onDisconnected は接続が切れたら、断られたのでなければ再接続を始める。
+func (app *Application) onDisconnected() {
+	if app.denied || app.reconnecting {
+		return
+	}
+	app.reconnecting = true
+	go app.reconnect()
+}
+
+// reconnect は同じ ID とトークンで、間隔を延ばしながら接続し直す。
+// 照準と射撃の送信待ちはそのまま残し、新しいチャネルが開いたら送る。
+func (app *Application) reconnect() {
+	delay := reconnectMinDelay
+	deadline := time.Now().Add(reconnectTimeout)
+	for time.Now().Before(deadline) && !app.denied {
+		time.Sleep(delay)
+		delay = min(delay*2, reconnectMaxDelay)
+		log.Println("reconnecting:", app.uid)
+		app.node.Close()
+		app.node = app.newNode()
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		err := app.Connect(ctx)
+		cancel()
+		if err != nil {
+			log.Println("reconnect failed:", err)
+			continue
+		}
+		app.OnDisconnect(app.onDisconnected)
+		app.reconnecting = false
+		log.Println("reconnected:", app.uid)
+		return
+	}
+	app.reconnecting = false
+	disconnected()
+}
+
+// heartbeat は照準の送信が詰まっていても接続を維持していることをホストに伝える。
+func (app *Application) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.Send(schema.Heartbeat{}, true)
+		}
+	}
 }
 
 func (app *Application) Close() error {
 	log.Println("application closed")
+	app.Send(schema.Leave{}, true)
 	return app.node.Close()
 }
 
@@ -145,6 +421,8 @@ func (app *Application) Run() {
 			scope.Get("classList").Call("remove", "flash")
 		})
 		app.fire = true
+		app.fireAt = time.Now()
+		app.feedback.Shot()
 		return nil
 	}))
 }
@@ -305,7 +583,30 @@ func IsASCIIOnly(s string) bool {
 	return true
 }
 
-func disconnected(n *node.Node) {
+// OnDisconnect は接続が切れたときに f を呼ぶようにする。
+func (app *Application) OnDisconnect(f func()) {
+	switch n := app.node.(type) {
+	case *node.Node:
+		n.OnDisconnect = func(*node.Node) { f() }
+	case *signaling.Node:
+		n.OnDisconnect = func(*signaling.Node) { f() }
+	}
+}
+
+func disconnected() {
+	showOverlay("接続に失敗しました")
+}
+
+// overlayShown は showOverlay を一度だけ表示するためのフラグ。
+var overlayShown bool
+
+// showOverlay は text と再接続ボタンを画面全体に表示する。
+// 参加を断られた後に切断が続いても、最初のメッセージを残す。
+func showOverlay(text string) {
+	if overlayShown {
+		return
+	}
+	overlayShown = true
 	overlay := document.Call("createElement", "div")
 	overlay.Get("style").Set("cssText",
 		"position:fixed;top:0;left:0;width:100%;height:100%;"+
@@ -313,7 +614,7 @@ func disconnected(n *node.Node) {
 			"align-items:center;justify-content:center;z-index:9999;")
 	msg := document.Call("createElement", "div")
 	msg.Get("style").Set("cssText", "color:white;font-size:24px;margin-bottom:20px;")
-	msg.Set("innerText", "接続に失敗しました")
+	msg.Set("innerText", text)
 	overlay.Call("appendChild", msg)
 	btn := document.Call("createElement", "button")
 	btn.Get("style").Set("cssText",
@@ -358,25 +659,22 @@ func main() {
 		}
 		if cnt%10 == 0 {
 			elm := document.Call("getElementById", "message")
-			info := fmt.Sprintf("x:%5.2f, y:%5.2f", x, y)
+			info := app.Message(x, y)
 			if elm.Get("innerText").String() != info {
 				elm.Set("innerText", info)
 			}
 		}
 		cnt++
 		if !skip {
-			info := schema.Info{
-				ID:   app.uid,
-				Name: app.name,
-				X:    x,
-				Y:    y,
-				Fire: app.fire,
-			}
-			b, _ := json.Marshal(info)
-			if err := app.Publish(b, info.Fire); err != nil {
-				return
+			if app.fire {
+				app.fire = false
+				if app.waiting() {
+					app.Send(schema.Ready{}, true)
+				} else {
+					app.Shoot(x, y, app.fireAt)
+				}
 			}
-			app.fire = false
+			app.Send(schema.Aim{X: x, Y: y}, false)
 		}
 	}
 	go func() {
@@ -385,10 +683,15 @@ func main() {
 			err := app.Connect(context.Background())
 			if err != nil {
 				log.Println("connect error:", err)
-				disconnected(nil)
+				disconnected()
 				return
 			}
-			app.node.OnDisconnect = disconnected
+			app.OnDisconnect(app.onDisconnected)
+			go app.heartbeat(app.ctx)
+			window.Call("addEventListener", "pagehide", js.FuncOf(func(this js.Value, args []js.Value) any {
+				app.Send(schema.Leave{}, true)
+				return nil
+			}))
 		}
 		app.Run()
 	}()
@@ -0,0 +1,76 @@
+package signaling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client は Server のメールボックスにアクセスする。
+type Client struct {
+	Endpoint string
+	// HTTP が nil の場合は http.DefaultClient を使う。
+	HTTP *http.Client
+}
+
+func (c *Client) url(id string) string {
+	return strings.TrimSuffix(c.Endpoint, "/") + "/" + url.PathEscape(id)
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.HTTP != nil {
+		return c.HTTP.Do(req)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// Send は to のメールボックスに msg を置く。
+func (c *Client) Send(ctx context.Context, to string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(to), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send to %s: %w", to, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send to %s: %s", to, resp.Status)
+	}
+	return nil
+}
+
+// Receive は id のメールボックスから Message を 1 つ取り出す。
+// サーバー側で PollTimeout が過ぎて何も届かなかった場合、ok は false。
+func (c *Client) Receive(ctx context.Context, id string) (msg Message, ok bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(id), nil)
+	if err != nil {
+		return msg, false, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return msg, false, fmt.Errorf("failed to receive for %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return msg, false, nil
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return msg, false, fmt.Errorf("failed to decode message: %w", err)
+		}
+		return msg, true, nil
+	default:
+		return msg, false, fmt.Errorf("failed to receive for %s: %s", id, resp.Status)
+	}
+}
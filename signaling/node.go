@@ -0,0 +1,227 @@
+package signaling
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+)
+
+// Transport は rtcconnect の node.Node とこのパッケージの Node に共通する、
+// 接続する側 (スコープ) の操作。
+type Transport interface {
+	ID() string
+	Connect(ctx context.Context, dest string) error
+	PeerConnection() *webrtc.PeerConnection
+	DataChannel() *webrtc.DataChannel
+	Close() error
+}
+
+// Node は rtcconnect の node.Node と同じ使い方で、Server を介して接続する。
+type Node struct {
+	// OnConnected はホストに新しいピアが接続しようとしたときに呼ばれる。
+	// ピアのデータチャネルを取りこぼさないよう、SDP の交換より前に呼ぶ。
+	OnConnected func(peer *Node)
+	// OnDisconnect は接続が失敗または切断されたときに一度だけ呼ばれる。
+	OnDisconnect func(peer *Node)
+	// Configuration は PeerConnection の設定。LAN 内なら ICE サーバーなしでつながる。
+	Configuration webrtc.Configuration
+
+	id     string
+	client *Client
+	pc     *webrtc.PeerConnection
+	dc     *webrtc.DataChannel
+
+	disconnectOnce sync.Once
+}
+
+var _ Transport = (*Node)(nil)
+
+// New は endpoint のシグナリングサーバーを使って接続する Node を作る。
+func New(endpoint, id string) *Node {
+	if id == "" {
+		uid, _ := uuid.NewV6()
+		id = uid.String()
+	}
+	return &Node{
+		id:     id,
+		client: &Client{Endpoint: endpoint},
+	}
+}
+
+// NewHost は Listen で接続を待ち受ける Node を作る。
+func NewHost(endpoint, id string) *Node {
+	return New(endpoint, id)
+}
+
+func (n *Node) ID() string {
+	return n.id
+}
+
+func (n *Node) PeerConnection() *webrtc.PeerConnection {
+	return n.pc
+}
+
+// DataChannel は Connect で開いたデフォルトのデータチャネル。
+// ホスト側のピアでは nil なので PeerConnection の OnDataChannel を使う。
+func (n *Node) DataChannel() *webrtc.DataChannel {
+	return n.dc
+}
+
+func (n *Node) Close() error {
+	if n.pc == nil {
+		return nil
+	}
+	return n.pc.Close()
+}
+
+// Connect は dest のホストに接続し、デフォルトのデータチャネルが開くまで待つ。
+// 失敗したときは作った PeerConnection を閉じる。
+func (n *Node) Connect(ctx context.Context, dest string) (err error) {
+	pc, err := webrtc.NewPeerConnection(n.Configuration)
+	if err != nil {
+		return fmt.Errorf("failed to create peer connection: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			pc.Close()
+		}
+	}()
+	n.pc = pc
+	n.watch()
+	dc, err := pc.CreateDataChannel("data", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create data channel: %w", err)
+	}
+	n.dc = dc
+	opened := make(chan struct{})
+	dc.OnOpen(func() { close(opened) })
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create offer: %w", err)
+	}
+	if err := n.exchange(ctx, offer); err != nil {
+		return err
+	}
+	// 前の試みへの遅れたアンサーを適用しないよう、試みごとにセッション ID を付ける
+	uid, _ := uuid.NewV6()
+	session := uid.String()
+	if err := n.client.Send(ctx, dest, Message{From: n.id, Session: session, Description: *pc.LocalDescription()}); err != nil {
+		return err
+	}
+	for {
+		msg, ok, err := n.client.Receive(ctx, n.id)
+		if err != nil {
+			return err
+		}
+		if !ok || msg.From != dest || msg.Session != session || msg.Description.Type != webrtc.SDPTypeAnswer {
+			continue
+		}
+		if err := pc.SetRemoteDescription(msg.Description); err != nil {
+			return fmt.Errorf("failed to set answer: %w", err)
+		}
+		break
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-opened:
+		return nil
+	}
+}
+
+// Listen は ctx が終わるまでオファーを待ち受け、届くたびに OnConnected を呼ぶ。
+func (n *Node) Listen(ctx context.Context) error {
+	for {
+		msg, ok, err := n.client.Receive(ctx, n.id)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			// サーバーの再起動などは待ってやり直す
+			log.Println("signaling receive failed:", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if !ok || msg.Description.Type != webrtc.SDPTypeOffer {
+			continue
+		}
+		go func() {
+			if err := n.accept(ctx, msg); err != nil {
+				log.Println("failed to accept peer:", msg.From, err)
+			}
+		}()
+	}
+}
+
+func (n *Node) accept(ctx context.Context, offer Message) error {
+	pc, err := webrtc.NewPeerConnection(n.Configuration)
+	if err != nil {
+		return fmt.Errorf("failed to create peer connection: %w", err)
+	}
+	peer := &Node{
+		OnDisconnect:  n.OnDisconnect,
+		Configuration: n.Configuration,
+		id:            offer.From,
+		client:        n.client,
+		pc:            pc,
+	}
+	peer.watch()
+	if n.OnConnected != nil {
+		n.OnConnected(peer)
+	}
+	if err := pc.SetRemoteDescription(offer.Description); err != nil {
+		pc.Close()
+		return fmt.Errorf("failed to set offer: %w", err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		pc.Close()
+		return fmt.Errorf("failed to create answer: %w", err)
+	}
+	if err := peer.exchange(ctx, answer); err != nil {
+		pc.Close()
+		return err
+	}
+	if err := n.client.Send(ctx, offer.From, Message{From: n.id, Session: offer.Session, Description: *pc.LocalDescription()}); err != nil {
+		pc.Close()
+		return err
+	}
+	return nil
+}
+
+// exchange は desc をローカルに設定し、ICE 候補が出揃うまで待つ。
+func (n *Node) exchange(ctx context.Context, desc webrtc.SessionDescription) error {
+	gathered := webrtc.GatheringCompletePromise(n.pc)
+	if err := n.pc.SetLocalDescription(desc); err != nil {
+		return fmt.Errorf("failed to set %s: %w", desc.Type, err)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-gathered:
+		return nil
+	}
+}
+
+func (n *Node) watch() {
+	n.pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			n.disconnectOnce.Do(func() {
+				if n.OnDisconnect != nil {
+					n.OnDisconnect(n)
+				}
+			})
+		}
+	})
+}
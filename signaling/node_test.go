@@ -0,0 +1,46 @@
+package signaling
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestNodeConnect(t *testing.T) {
+	srv := httptest.NewServer(NewServer())
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan string, 1)
+	host := NewHost(srv.URL, "host")
+	host.OnConnected = func(peer *Node) {
+		t.Cleanup(func() { peer.Close() })
+		peer.PeerConnection().OnDataChannel(func(dc *webrtc.DataChannel) {
+			dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+				received <- peer.ID() + ":" + string(msg.Data)
+			})
+		})
+	}
+	go host.Listen(ctx)
+
+	scope := New(srv.URL, "scope")
+	defer scope.Close()
+	if err := scope.Connect(ctx, "host"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := scope.DataChannel().SendText("hello"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != "scope:hello" {
+			t.Errorf("received %q, want %q", got, "scope:hello")
+		}
+	case <-ctx.Done():
+		t.Fatal("no message received by the host")
+	}
+}
@@ -0,0 +1,140 @@
+// Package signaling は rtcconnect の公開シグナリングサービスの代わりに使える、
+// LAN やテスト向けの小さなシグナリングを提供する。
+//
+// サーバーは ID ごとのメールボックスを持つだけで、ピアは相手の ID 宛てに
+// SDP を POST し、自分の ID のメールボックスをロングポーリングして受け取る。
+// ICE 候補は SDP にまとめて送るため、トリクル ICE は使わない。
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// PollTimeout はメールボックスが空のときに GET が待つ時間。
+	PollTimeout = 25 * time.Second
+	// mailboxSize はメールボックスに溜められるメッセージの数。
+	mailboxSize = 16
+	// mailboxIdle はアクセスのないメールボックスを捨てるまでの時間。
+	mailboxIdle = 5 * time.Minute
+)
+
+// Message はメールボックスでやりとりするオファーまたはアンサー。
+// アンサーにはオファーの Session をそのまま入れ、どの接続の試みへの応答かを示す。
+type Message struct {
+	From        string                    `json:"from"`
+	Session     string                    `json:"session,omitempty"`
+	Description webrtc.SessionDescription `json:"sdp"`
+}
+
+type mailbox struct {
+	ch       chan Message
+	lastSeen time.Time
+}
+
+// Server はメールボックス方式のシグナリングサーバー。
+//
+//	POST /{id}  id 宛てに Message を置く
+//	GET  /{id}  id 宛ての Message を 1 つ取り出す。PollTimeout の間なければ 204
+type Server struct {
+	mu          sync.Mutex
+	mailboxes   map[string]*mailbox
+	pollTimeout time.Duration
+}
+
+func NewServer() *Server {
+	return &Server{
+		mailboxes:   make(map[string]*mailbox),
+		pollTimeout: PollTimeout,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// スコープとホストはどちらも別オリジンのページから呼ぶ
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	id := strings.Trim(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case id == "" || strings.Contains(id, "/"):
+		http.NotFound(w, r)
+	case r.Method == http.MethodPost:
+		s.post(w, r, id)
+	case r.Method == http.MethodGet:
+		s.get(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) post(w http.ResponseWriter, r *http.Request, id string) {
+	var msg Message
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case s.mailbox(id).ch <- msg:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "mailbox full", http.StatusServiceUnavailable)
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, id string) {
+	timer := time.NewTimer(s.pollTimeout)
+	defer timer.Stop()
+	select {
+	case msg := <-s.mailbox(id).ch:
+		// 取り出してから渡せなかったメッセージは、次の GET のためにメールボックスへ戻す
+		if r.Context().Err() != nil {
+			s.putBack(id, msg)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(msg); err != nil {
+			s.putBack(id, msg)
+		}
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+	}
+}
+
+// putBack は渡せなかった msg を id のメールボックスに戻す。溢れるなら捨てる。
+func (s *Server) putBack(id string, msg Message) {
+	select {
+	case s.mailbox(id).ch <- msg:
+	default:
+		log.Println("signaling: mailbox full, message dropped:", id)
+	}
+}
+
+// mailbox は id のメールボックスを返す。ついでに放置されたメールボックスを捨てる。
+func (s *Server) mailbox(id string) *mailbox {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, mb := range s.mailboxes {
+		if now.Sub(mb.lastSeen) > mailboxIdle {
+			delete(s.mailboxes, k)
+		}
+	}
+	mb, ok := s.mailboxes[id]
+	if !ok {
+		mb = &mailbox{ch: make(chan Message, mailboxSize)}
+		s.mailboxes[id] = mb
+	}
+	mb.lastSeen = now
+	return mb
+}
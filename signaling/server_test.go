@@ -0,0 +1,80 @@
+package signaling
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestServerPollTimeout(t *testing.T) {
+	s := NewServer()
+	s.pollTimeout = 50 * time.Millisecond
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	client := &Client{Endpoint: srv.URL}
+	if _, ok, err := client.Receive(context.Background(), "empty"); ok || err != nil {
+		t.Errorf("Receive = ok %v, err %v, want a timeout without an error", ok, err)
+	}
+}
+
+func TestServerSendReceive(t *testing.T) {
+	srv := httptest.NewServer(NewServer())
+	defer srv.Close()
+	client := &Client{Endpoint: srv.URL}
+	ctx := context.Background()
+
+	want := Message{
+		From:        "scope",
+		Session:     "s1",
+		Description: webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: "v=0"},
+	}
+	if err := client.Send(ctx, "host", want); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got, ok, err := client.Receive(ctx, "host")
+	if err != nil || !ok {
+		t.Fatalf("Receive = ok %v, err %v", ok, err)
+	}
+	if got != want {
+		t.Errorf("Receive = %+v, want %+v", got, want)
+	}
+}
+
+// failingWriter は本文の書き込みに失敗する http.ResponseWriter。
+type failingWriter struct {
+	header http.Header
+}
+
+func (w *failingWriter) Header() http.Header        { return w.header }
+func (w *failingWriter) Write([]byte) (int, error)  { return 0, errors.New("client gone") }
+func (w *failingWriter) WriteHeader(statusCode int) {}
+
+func TestServerKeepsUndeliveredMessage(t *testing.T) {
+	s := NewServer()
+	s.pollTimeout = 50 * time.Millisecond
+	msg := Message{From: "scope", Description: webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: "v=0"}}
+	s.mailbox("host").ch <- msg
+
+	s.get(&failingWriter{header: http.Header{}}, httptest.NewRequest(http.MethodGet, "/host", nil), "host")
+
+	rec := httptest.NewRecorder()
+	s.get(rec, httptest.NewRequest(http.MethodGet, "/host", nil), "host")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the message to be delivered again", rec.Code)
+	}
+}
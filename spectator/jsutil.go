@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/url"
+	"syscall/js"
+)
+
+var (
+	document = js.Global().Get("document")
+	window   = js.Global().Get("window")
+	location = js.Global().Get("location")
+	params   url.Values
+)
+
+func init() {
+	u, _ := url.Parse(location.Get("href").String())
+	params = u.Query()
+}
+
+func GetParam(key string) string {
+	return params.Get(key)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nobonobo/gun-shooter/schema"
+	"github.com/nobonobo/gun-shooter/signaling"
+	"github.com/nobonobo/rtcconnect/node"
+	"github.com/pion/webrtc/v4"
+)
+
+// cursorColors はプレイヤーの照準を描き分ける色。順位ではなく ID で決める。
+var cursorColors = []string{
+	"#e74c3c", "#3498db", "#2ecc71", "#f1c40f",
+	"#9b59b6", "#e67e22", "#1abc9c", "#ecf0f1",
+}
+
+// teamColors はチーム戦でのチームの色。ホスト画面と同じ並びにする。
+var teamColors = []string{"#e74c3c", "#3498db", "#2ecc71", "#f1c40f"}
+
+// targetColors はターゲットの種類ごとの外枠、内側、中心の色。ホスト画面と同じにする。
+var targetColors = map[string][3]string{
+	"normal":    {"white", "rgba(255,40,40,0.8)", "yellow"},
+	"bonus":     {"yellow", "rgba(255,160,0,0.86)", "white"},
+	"penalty":   {"white", "rgba(40,120,255,0.8)", "#aaddff"},
+	"armored":   {"#444444", "rgba(140,140,150,0.9)", "#dddddd"},
+	"time":      {"white", "rgba(40,200,90,0.8)", "yellow"},
+	"shrinking": {"white", "rgba(170,60,220,0.8)", "yellow"},
+}
+
+// view はホスト画面のピクセル座標をキャンバスに縦横比を保って写す。
+type view struct {
+	feed          *schema.Feed
+	scale, ox, oy float64
+}
+
+func newView(feed *schema.Feed, w, h float64) view {
+	if feed.Width <= 0 || feed.Height <= 0 {
+		// 画面サイズを送ってこないホストではキャンバス全体を使う
+		return view{feed: &schema.Feed{Width: w, Height: h}, scale: 1}
+	}
+	scale := math.Min(w/feed.Width, h/feed.Height)
+	return view{
+		feed:  feed,
+		scale: scale,
+		ox:    (w - feed.Width*scale) / 2,
+		oy:    (h - feed.Height*scale) / 2,
+	}
+}
+
+// point は正規化座標をキャンバスの座標にする。
+func (v view) point(x, y float64) (float64, float64) {
+	f := v.feed
+	return v.ox + (f.Inset+x*(f.Width-2*f.Inset))*v.scale,
+		v.oy + (f.Inset+y*(f.Height-2*f.Inset))*v.scale
+}
+
+// Spectator はホストから Feed を受け取って試合の様子を表示する。
+// 入力は送らないので、照準や射撃のチャネルは開かない。
+type Spectator struct {
+	uid    string
+	dest   string
+	signal string // 空なら rtcconnect の公開サービスを使う
+	node   signaling.Transport
+	seq    uint32
+	denied bool
+
+	mu     sync.Mutex
+	feed   *schema.Feed
+	colors map[string]string
+
+	canvas js.Value
+	ctx2d  js.Value
+	board  string // 直前に描いた順位表。変わったときだけ DOM を書き換える
+}
+
+func NewSpectator() *Spectator {
+	uid, _ := uuid.NewV6()
+	s := &Spectator{
+		uid:    uid.String(),
+		dest:   GetParam("dest"),
+		signal: GetParam("signal"),
+		colors: map[string]string{},
+	}
+	if s.signal != "" {
+		s.node = signaling.New(s.signal, s.uid)
+	} else {
+		s.node = node.New(s.uid)
+	}
+	return s
+}
+
+// Send は msg を Envelope に包んでデフォルトチャネルに送る。
+func (s *Spectator) Send(msg schema.Message) error {
+	s.seq++
+	dc := s.node.DataChannel()
+	if dc == nil || dc.ReadyState() != webrtc.DataChannelStateOpen {
+		return fmt.Errorf("data channel not open")
+	}
+	b, err := schema.Encode(msg, s.seq, time.Now())
+	if err != nil {
+		return err
+	}
+	return dc.SendText(string(b))
+}
+
+func (s *Spectator) Connect(ctx context.Context) error {
+	if err := s.node.Connect(ctx, s.dest); err != nil {
+		return err
+	}
+	s.node.DataChannel().OnMessage(s.onMessage)
+	name := GetParam("name")
+	if name == "" {
+		name = "Spectator"
+	}
+	return s.Send(schema.Hello{
+		ID:   s.uid,
+		Name: name,
+		Role: schema.RoleSpectator,
+		PIN:  GetParam("pin"),
+	})
+}
+
+// onMessage はホストから届いたメッセージを処理する。
+func (s *Spectator) onMessage(msg webrtc.DataChannelMessage) {
+	_, m, err := schema.Decode(msg.Data)
+	if err != nil {
+		log.Println("failed to decode message:", err)
+		return
+	}
+	switch m := m.(type) {
+	case schema.Ping:
+		s.Send(schema.Pong{Ping: m.Time, Time: time.Now().UnixMilli()})
+	case schema.Feed:
+		s.mu.Lock()
+		s.feed = &m
+		s.mu.Unlock()
+	case schema.Denied:
+		log.Println("denied:", m.Reason)
+		s.denied = true
+		setMessage("観戦を拒否されました: " + m.Reason)
+	}
+}
+
+// OnDisconnect は接続が切れたときに f を呼ぶようにする。
+func (s *Spectator) OnDisconnect(f func()) {
+	switch n := s.node.(type) {
+	case *node.Node:
+		n.OnDisconnect = func(*node.Node) { f() }
+	case *signaling.Node:
+		n.OnDisconnect = func(*signaling.Node) { f() }
+	}
+}
+
+// heartbeat は観戦を続けていることをホストに伝える。
+func (s *Spectator) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Send(schema.Heartbeat{})
+		}
+	}
+}
+
+// color はプレイヤーを描く色を返す。チーム戦ではチームの色を使い、
+// 個人戦では初めて見た ID に次の色を割り当てる。
+func (s *Spectator) color(p schema.FeedPlayer) string {
+	if p.Team > 0 && p.Team <= len(teamColors) {
+		return teamColors[p.Team-1]
+	}
+	c, ok := s.colors[p.ID]
+	if !ok {
+		c = cursorColors[len(s.colors)%len(cursorColors)]
+		s.colors[p.ID] = c
+	}
+	return c
+}
+
+// Run は requestAnimationFrame で Feed を描き続ける。
+func (s *Spectator) Run() {
+	s.canvas = document.Call("getElementById", "field")
+	s.ctx2d = s.canvas.Call("getContext", "2d")
+	var frame js.Func
+	frame = js.FuncOf(func(this js.Value, args []js.Value) any {
+		s.render()
+		window.Call("requestAnimationFrame", frame)
+		return nil
+	})
+	window.Call("requestAnimationFrame", frame)
+}
+
+func (s *Spectator) render() {
+	w, h := window.Get("innerWidth").Float(), window.Get("innerHeight").Float()
+	if s.canvas.Get("width").Float() != w || s.canvas.Get("height").Float() != h {
+		s.canvas.Set("width", w)
+		s.canvas.Set("height", h)
+	}
+	c := s.ctx2d
+	c.Call("clearRect", 0, 0, w, h)
+
+	s.mu.Lock()
+	feed := s.feed
+	s.mu.Unlock()
+	if feed == nil {
+		return
+	}
+
+	// 的と照準はホストの画面と同じ 0〜1 の座標で届くので、ホストと同じ内側の余白で写す
+	v := newView(feed, w, h)
+	c.Set("strokeStyle", "#333333")
+	c.Set("lineWidth", 1)
+	c.Call("strokeRect", v.ox, v.oy, v.feed.Width*v.scale, v.feed.Height*v.scale)
+	for _, t := range feed.Targets {
+		s.renderTarget(v, t)
+	}
+	c.Set("lineWidth", 3)
+	c.Set("font", "16px sans-serif")
+	for _, p := range feed.Players {
+		if !p.Active {
+			continue
+		}
+		x, y := v.point(p.Cursor.X, p.Cursor.Y)
+		color := s.color(p)
+		c.Set("strokeStyle", color)
+		c.Set("fillStyle", color)
+		c.Call("beginPath")
+		c.Call("arc", x, y, 12, 0, 2*math.Pi)
+		c.Call("moveTo", x-18, y)
+		c.Call("lineTo", x+18, y)
+		c.Call("moveTo", x, y-18)
+		c.Call("lineTo", x, y+18)
+		c.Call("stroke")
+		c.Call("fillText", p.Name, x+16, y-16)
+	}
+
+	setText("mode", feed.Mode)
+	setText("timer", fmt.Sprintf("%.1f", float64(feed.Remaining)/1000))
+	s.renderBoard(feed.Players)
+}
+
+// renderTarget はターゲットをホスト画面と同じ大きさと色で描く。
+func (s *Spectator) renderTarget(v view, t schema.FeedTarget) {
+	c := s.ctx2d
+	x, y := v.point(t.X, t.Y)
+	radius, bullseye := t.Radius*v.scale, t.Bullseye*v.scale
+	colors, ok := targetColors[t.Kind]
+	if !ok {
+		colors = targetColors["normal"]
+	}
+	border := 4 * v.scale
+	if t.Kind == "armored" {
+		border = 12 * v.scale
+	}
+	for i, r := range []float64{radius, radius - border, bullseye} {
+		if r <= 0 {
+			continue
+		}
+		c.Call("beginPath")
+		c.Call("arc", x, y, r, 0, 2*math.Pi)
+		c.Set("fillStyle", colors[i])
+		c.Call("fill")
+	}
+	if t.Kind == "penalty" {
+		// 撃ってはいけない印
+		d := radius * 0.6
+		c.Set("strokeStyle", "white")
+		c.Set("lineWidth", 10*v.scale)
+		c.Call("beginPath")
+		c.Call("moveTo", x-d, y-d)
+		c.Call("lineTo", x+d, y+d)
+		c.Call("moveTo", x+d, y-d)
+		c.Call("lineTo", x-d, y+d)
+		c.Call("stroke")
+	}
+}
+
+// renderBoard は順位表を書き換える。Feed の Players は順位順に並んでいる。
+func (s *Spectator) renderBoard(players []schema.FeedPlayer) {
+	html := ""
+	for _, p := range players {
+		class := ""
+		if !p.Active {
+			class = "inactive"
+		}
+		html += fmt.Sprintf(`<li class="%s" style="border-left:6px solid %s;padding-left:6px">%s: %d</li>`,
+			class, s.color(p), escape(p.Name), p.Score)
+	}
+	if html == s.board {
+		return
+	}
+	s.board = html
+	document.Call("getElementById", "leaderboard").Set("innerHTML", html)
+}
+
+func escape(text string) string {
+	elm := document.Call("createElement", "span")
+	elm.Set("textContent", text)
+	return elm.Get("innerHTML").String()
+}
+
+func setText(id, text string) {
+	elm := document.Call("getElementById", id)
+	if elm.Get("innerText").String() != text {
+		elm.Set("innerText", text)
+	}
+}
+
+func setMessage(text string) {
+	setText("message", text)
+}
+
+// reloadDelay は切断してからページを読み直して接続し直すまでの時間。
+const reloadDelay = 5 * time.Second
+
+func main() {
+	fmt.Println("wasm instance started")
+	defer fmt.Println("wasm instance ended")
+	s := NewSpectator()
+	s.Run()
+	go func() {
+		setMessage("接続中...")
+		if err := s.Connect(context.Background()); err != nil {
+			log.Println("connect error:", err)
+			setMessage("接続に失敗しました")
+			return
+		}
+		setMessage("")
+		ctx, cancel := context.WithCancel(context.Background())
+		go s.heartbeat(ctx)
+		s.OnDisconnect(func() {
+			cancel()
+			if s.denied {
+				return
+			}
+			setMessage("切断されました。再接続します...")
+			time.AfterFunc(reloadDelay, func() {
+				location.Call("reload")
+			})
+		})
+		window.Call("addEventListener", "pagehide", js.FuncOf(func(this js.Value, args []js.Value) any {
+			s.Send(schema.Leave{})
+			return nil
+		}))
+	}()
+	select {}
+}